@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/validation"
+)
+
+// Output formats
+const (
+	formatText    = "text"
+	formatJSON    = "json"
+	formatExplain = "explain"
+)
+
+// runCalculate validates and calculates a single patient
+func runCalculate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ivfcalc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var req calculator.CalculateRequest
+	var reasons string
+	input := fs.String("input", "", "read the request as JSON from `file` (- for stdin)")
+	format := fs.String("format", formatText, "output `format`: text, json or explain")
+	formulasPath := fs.String("formulas", os.Getenv("FORMULAS_PATH"), "formula CSV or JSON `file` (default: embedded CDC formulas)")
+
+	fs.IntVar(&req.Age, "age", 0, "age in years")
+	fs.IntVar(&req.WeightLbs, "weight-lbs", 0, "weight in pounds")
+	fs.IntVar(&req.HeightFt, "height-ft", 0, "height, feet part")
+	fs.IntVar(&req.HeightIn, "height-in", 0, "height, inches part")
+	fs.Float64Var(&req.WeightKg, "weight-kg", 0, "weight in kilograms")
+	fs.Float64Var(&req.HeightCm, "height-cm", 0, "height in centimeters")
+	fs.Float64Var(&req.BMI, "bmi", 0, "body mass index, instead of weight and height")
+	fs.StringVar(&req.EggSource, "egg-source", "", "own or donor")
+	fs.StringVar(&req.PriorIvfCycles, "prior-ivf", "", "yes or no, whether IVF was attempted before (own eggs)")
+	fs.IntVar(&req.PriorPregnancies, "prior-pregnancies", 0, "prior pregnancies (0, 1 or 2 for 2+)")
+	fs.IntVar(&req.PriorBirths, "prior-births", 0, "prior live births (0, 1 or 2 for 2+)")
+	fs.StringVar(&reasons, "reasons", "", "comma separated infertility `reasons`")
+	fs.IntVar(&req.Retrievals, "retrievals", 0, "intended egg retrievals (1-3)")
+	fs.StringVar(&req.ModelVersion, "model-version", "", "formula version (default: latest)")
+	fs.Float64Var(&req.ConfidenceLevel, "confidence-level", 0, "level of the confidence interval (default 0.95)")
+	fs.StringVar(&req.Rounding, "rounding", "", "rounding `policy` of percentages: ceil (default), half_even, floor, integer or raw")
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		errorf(stderr, "unexpected arguments: %s", strings.Join(fs.Args(), " "))
+		return exitUsage
+	}
+	switch *format {
+	case formatText, formatJSON, formatExplain:
+	default:
+		errorf(stderr, "unknown format %q", *format)
+		return exitUsage
+	}
+
+	// Flags that were set explicitly override the JSON input
+	if *input != "" {
+		fromFile, err := readRequest(*input, stdin)
+		if err != nil {
+			errorf(stderr, "%v", err)
+			return exitError
+		}
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		req = mergeRequest(fromFile, req, set)
+	}
+	if reasons != "" {
+		req.Reasons = splitList(reasons)
+	}
+
+	// The formulas are loaded first as they limit the retrievals
+	store, err := loadFormulaStore(*formulasPath)
+	if err != nil {
+		errorf(stderr, "failed to load formulas: %v", err)
+		return exitError
+	}
+
+	if errors := validation.ValidateCalculateRequest(req, store.MaxRetrievals()); len(errors) > 0 {
+		for _, fe := range errors {
+			errorf(stderr, "%s %s", fe.Field, fe.Message)
+		}
+		return exitValidation
+	}
+
+	for _, warning := range validation.WarnCalculateRequest(req) {
+		errorf(stderr, "warning: %s", warning.Message)
+	}
+
+	result, err := calculator.New(store).Calculate(context.Background(), req)
+	if err != nil {
+		errorf(stderr, "%v", err)
+		return exitError
+	}
+
+	switch *format {
+	case formatJSON:
+		result.Breakdown = nil
+		return writeJSON(stdout, stderr, result)
+	case formatExplain:
+		writeExplain(stdout, result)
+	default:
+		writeText(stdout, result)
+	}
+	return exitOK
+}
+
+// readRequest decodes a CalculateRequest from path, or from stdin when path is "-"
+func readRequest(path string, stdin io.Reader) (calculator.CalculateRequest, error) {
+	var req calculator.CalculateRequest
+
+	r := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return req, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return req, fmt.Errorf("invalid request JSON: %w", err)
+	}
+	return req, nil
+}
+
+// mergeRequest copies the fields of flags whose flag name is in set onto base
+func mergeRequest(base, flags calculator.CalculateRequest, set map[string]bool) calculator.CalculateRequest {
+	if set["age"] {
+		base.Age = flags.Age
+	}
+	if set["weight-lbs"] {
+		base.WeightLbs = flags.WeightLbs
+	}
+	if set["height-ft"] {
+		base.HeightFt = flags.HeightFt
+	}
+	if set["height-in"] {
+		base.HeightIn = flags.HeightIn
+	}
+	if set["weight-kg"] {
+		base.WeightKg = flags.WeightKg
+	}
+	if set["height-cm"] {
+		base.HeightCm = flags.HeightCm
+	}
+	if set["bmi"] {
+		base.BMI = flags.BMI
+	}
+	if set["egg-source"] {
+		base.EggSource = flags.EggSource
+	}
+	if set["prior-ivf"] {
+		base.PriorIvfCycles = flags.PriorIvfCycles
+	}
+	if set["prior-pregnancies"] {
+		base.PriorPregnancies = flags.PriorPregnancies
+	}
+	if set["prior-births"] {
+		base.PriorBirths = flags.PriorBirths
+	}
+	if set["retrievals"] {
+		base.Retrievals = flags.Retrievals
+	}
+	if set["model-version"] {
+		base.ModelVersion = flags.ModelVersion
+	}
+	if set["confidence-level"] {
+		base.ConfidenceLevel = flags.ConfidenceLevel
+	}
+	if set["rounding"] {
+		base.Rounding = flags.Rounding
+	}
+	return base
+}
+
+// loadFormulaStore loads formulas from path, or the embedded CSV when path is empty
+func loadFormulaStore(path string) (*calculator.FormulaStore, error) {
+	if path == "" {
+		return calculator.DefaultFormulaStore()
+	}
+	return calculator.LoadFormulasFile(path)
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func writeJSON(stdout, stderr io.Writer, v any) int {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		errorf(stderr, "%v", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func writeText(w io.Writer, result calculator.CalculateResponse) {
+	percent := func(p float64) string { return formatPercent(p, result.Rounding) }
+	fmt.Fprintf(w, "Cumulative chance of live birth: %s\n", percent(result.CumulativeChancePercent))
+	if len(result.ChancesByRetrieval) > 1 {
+		for _, chance := range result.ChancesByRetrieval {
+			fmt.Fprintf(w, "  after %d retrievals: %s\n", chance.Retrievals, percent(chance.CumulativeChancePercent))
+		}
+	}
+	fmt.Fprintf(w, "Formula: %s (model version %s)\n", result.FormulaID, result.ModelVersion)
+	if interval := result.ConfidenceInterval; interval != nil {
+		if interval.Available {
+			fmt.Fprintf(w, "%.4g%% confidence interval: %s to %s\n", interval.Level*100, percent(*interval.LowerPercent), percent(*interval.UpperPercent))
+		} else {
+			fmt.Fprintf(w, "%.4g%% confidence interval: unavailable, %s\n", interval.Level*100, interval.Reason)
+		}
+	}
+}
+
+// formatPercent formats a percentage with the precision of its rounding policy
+func formatPercent(p float64, rounding string) string {
+	switch rounding {
+	case calculator.RoundInteger:
+		return fmt.Sprintf("%.0f%%", p)
+	case calculator.RoundRaw:
+		return strconv.FormatFloat(p, 'f', -1, 64) + "%"
+	default:
+		return fmt.Sprintf("%.2f%%", p)
+	}
+}
+
+func writeExplain(w io.Writer, result calculator.CalculateResponse) {
+	writeText(w, result)
+
+	breakdown := result.Breakdown
+	fmt.Fprintf(w, "BMI: %.2f\n\n", breakdown.BMI)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "term\tcoefficient\tvalue\tcontribution\t")
+	for _, term := range breakdown.Terms {
+		fmt.Fprintf(tw, "%s\t%.8g\t%.6g\t%.6f\t\n", term.Name, term.Coefficient, term.Value, term.Contribution)
+	}
+	fmt.Fprintf(tw, "logit\t\t\t%.6f\t\n", breakdown.Logit)
+	fmt.Fprintf(tw, "probability\t\t\t%.6f\t\n", breakdown.Probability)
+	tw.Flush()
+}
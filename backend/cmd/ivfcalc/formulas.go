@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"ivf-calculator-backend/internal/calculator"
+)
+
+// runLint validates formula files and reports every load problem
+func runLint(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ivfcalc lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: ivfcalc lint FILE...")
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, path := range fs.Args() {
+		store, ok := lintFile(path, stdout, stderr)
+		if !ok {
+			code = exitValidation
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: ok, %d formulas (checksum %s)\n", path, store.Len(), store.Checksum())
+	}
+	return code
+}
+
+// runDiff compares two formula files and reports coefficient changes and
+// their impact on a standard grid of synthetic patients
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ivfcalc diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", formatText, "output `format`: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: ivfcalc diff [-format text|json] OLD NEW")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	if *format != formatText && *format != formatJSON {
+		errorf(stderr, "unknown format %q", *format)
+		return exitUsage
+	}
+
+	oldStore, oldOK := lintFile(fs.Arg(0), stdout, stderr)
+	newStore, newOK := lintFile(fs.Arg(1), stdout, stderr)
+	if !oldOK || !newOK {
+		return exitValidation
+	}
+
+	diff := calculator.DiffStores(oldStore, newStore)
+	if *format == formatJSON {
+		return writeJSON(stdout, stderr, diff)
+	}
+	writeDiff(stdout, diff)
+	return exitOK
+}
+
+// runConvert writes a formula CSV in the declarative JSON format, which
+// evaluates to identical results
+func runConvert(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ivfcalc convert", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: ivfcalc convert FILE.csv > formulas.json")
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	store, ok := lintFile(fs.Arg(0), stdout, stderr)
+	if !ok {
+		return exitValidation
+	}
+	if err := calculator.WriteFormulaFile(stdout, store); err != nil {
+		errorf(stderr, "%v", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// lintFile loads the formula file at path, printing its problems if it is invalid
+func lintFile(path string, stdout, stderr io.Writer) (*calculator.FormulaStore, bool) {
+	store, err := calculator.LoadFormulasFile(path)
+	if err == nil {
+		return store, true
+	}
+
+	var report *calculator.LoadReport
+	if !errors.As(err, &report) {
+		errorf(stderr, "%s: %v", path, err)
+		return nil, false
+	}
+	for _, problem := range report.Problems {
+		fmt.Fprintf(stdout, "%s: %s\n", path, problem)
+	}
+	return nil, false
+}
+
+func writeDiff(w io.Writer, diff calculator.StoreDiff) {
+	if !diff.Changed() {
+		fmt.Fprintln(w, "No formula changes")
+	}
+	for _, parameters := range diff.Removed {
+		fmt.Fprintf(w, "Removed formula for %s\n", parameters)
+	}
+	for _, parameters := range diff.Added {
+		fmt.Fprintf(w, "Added formula for %s\n", parameters)
+	}
+	for _, formula := range diff.Formulas {
+		id := formula.FormulaID
+		if formula.OldFormulaID != formula.FormulaID {
+			id = formula.OldFormulaID + " -> " + formula.FormulaID
+		}
+		fmt.Fprintf(w, "Formula %s (%s)\n", id, formula.Parameters)
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, c := range formula.Coefficients {
+			fmt.Fprintf(tw, "  %s\t%s\t->\t%s\t\n", c.Coefficient, formatCoefficient(c.Old), formatCoefficient(c.New))
+		}
+		tw.Flush()
+	}
+
+	impact := diff.Impact
+	fmt.Fprintf(w, "\nImpact on %d synthetic patients: %d changed, max change %.2f points, mean change %.2f points\n",
+		impact.Patients, impact.Changed, impact.MaxChange, impact.MeanChange)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  formula\tpatients\tchanged\tmax\tmean\t")
+	for _, f := range diff.ImpactByFormula {
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%.2f\t%.2f\t\n", f.FormulaID, f.Patients, f.Changed, f.MaxChange, f.MeanChange)
+	}
+	tw.Flush()
+	if p := impact.MaxChangePatient; p != nil {
+		fmt.Fprintf(w, "Largest change: %s\n", describePatient(p))
+	}
+	if impact.NewlyFailing > 0 {
+		fmt.Fprintf(w, "%d patients can no longer be calculated, first: %s\n", impact.NewlyFailing, describePatient(impact.NewlyFailingPatient))
+	}
+	if impact.NewlyCalculated > 0 {
+		fmt.Fprintf(w, "%d patients can only be calculated by the new formulas\n", impact.NewlyCalculated)
+	}
+	if impact.Skipped > 0 {
+		fmt.Fprintf(w, "%d patients cannot be calculated by either\n", impact.Skipped)
+	}
+}
+
+func describePatient(p *calculator.CalculateRequest) string {
+	return fmt.Sprintf("age %d, BMI %g, egg source %s, prior IVF %q, reasons %s, prior pregnancies %d, prior births %d",
+		p.Age, p.BMI, p.EggSource, p.PriorIvfCycles, strings.Join(p.Reasons, ","), p.PriorPregnancies, p.PriorBirths)
+}
+
+func formatCoefficient(v *float64) string {
+	if v == nil {
+		return "(none)"
+	}
+	return fmt.Sprintf("%.8g", *v)
+}
@@ -0,0 +1,53 @@
+// Command ivfcalc calculates IVF success chances without running the HTTP server.
+//
+// Patient parameters come from flags, from a JSON file in the same shape as the
+// POST /api/calculate body, or both (flags override the file):
+//
+//	ivfcalc -age 32 -bmi 22.8 -egg-source own -prior-ivf no -reasons endometriosis
+//	echo '{"age": 32, ...}' | ivfcalc -input - -format json
+//
+// The lint and diff subcommands check formula files before they are deployed,
+// and convert rewrites a formula CSV in the declarative JSON format:
+//
+//	ivfcalc lint new_formulas.csv
+//	ivfcalc diff current_formulas.csv new_formulas.csv
+//	ivfcalc convert new_formulas.csv > new_formulas.json
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitValidation = 3
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command with args and returns the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "lint":
+			return runLint(args[1:], stdout, stderr)
+		case "diff":
+			return runDiff(args[1:], stdout, stderr)
+		case "convert":
+			return runConvert(args[1:], stdout, stderr)
+		}
+	}
+	return runCalculate(args, stdin, stdout, stderr)
+}
+
+// errorf prints an error message prefixed with the command name
+func errorf(stderr io.Writer, format string, args ...any) {
+	fmt.Fprintf(stderr, "ivfcalc: "+format+"\n", args...)
+}
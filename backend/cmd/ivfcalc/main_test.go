@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ivf-calculator-backend/internal/calculator"
+)
+
+var scenario1Args = []string{
+	"-age", "32", "-weight-lbs", "141", "-height-ft", "5", "-height-in", "6",
+	"-egg-source", "own", "-prior-ivf", "no", "-prior-pregnancies", "1", "-prior-births", "1",
+	"-reasons", "endometriosis,ovulatory_disorder",
+}
+
+func TestRun_Text(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run(scenario1Args, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "Cumulative chance of live birth: 62.21%\nFormula: 1-3") {
+		t.Errorf("Unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestRun_Rounding(t *testing.T) {
+	tests := []struct {
+		rounding string
+		want     string
+	}{
+		{rounding: "floor", want: "Cumulative chance of live birth: 62.20%\n"},
+		{rounding: "integer", want: "Cumulative chance of live birth: 62%\n"},
+		{rounding: "raw", want: "Cumulative chance of live birth: 62.203762076568815%\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rounding, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(append(scenario1Args, "-rounding", tt.rounding), nil, &stdout, &stderr); code != exitOK {
+				t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+			}
+			if !strings.HasPrefix(stdout.String(), tt.want) {
+				t.Errorf("Unexpected output:\n%s", stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_Explain(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run(append(scenario1Args, "-format", "explain"), nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	for _, want := range []string{"endometriosis_true", "prior_live_births_1", "logit", "probability"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestRun_JSONInputWithFlagOverride(t *testing.T) {
+	stdin := strings.NewReader(`{"age": 40, "bmi": 22.8, "eggSource": "donor", "reasons": ["unknown"]}`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-input", "-", "-format", "json", "-age", "32"}, stdin, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+
+	var result calculator.CalculateResponse
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if result.CumulativeChancePercent != 56.8 || result.FormulaID != "14-16" {
+		t.Errorf("Expected 56.8 from formula 14-16 for age 32, got %+v", result)
+	}
+}
+
+func TestRun_ValidationErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := append(append([]string(nil), scenario1Args...), "-age", "60")
+	if code := run(args, nil, &stdout, &stderr); code != exitValidation {
+		t.Fatalf("Expected exit code %d, got %d", exitValidation, code)
+	}
+	if stderr.String() != "ivfcalc: age must be between 20 and 50\n" {
+		t.Errorf("Unexpected errors: %q", stderr.String())
+	}
+}
+
+func TestRun_Warnings(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := append(append([]string(nil), scenario1Args...), "-age", "46")
+	if code := run(args, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if stderr.String() != "ivfcalc: warning: few patients over 45 used their own eggs, so the model is less reliable\n" {
+		t.Errorf("Unexpected warnings: %q", stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "Cumulative chance of live birth: ") {
+		t.Errorf("Unexpected output:\n%s", stdout.String())
+	}
+}
+
+// writeFormulas writes the embedded formula CSV to a temp file, applying replace
+func writeFormulas(t *testing.T, replace func(string) string) string {
+	t.Helper()
+	data, err := os.ReadFile("../../internal/calculator/ivf_success_formulas.csv")
+	if err != nil {
+		t.Fatalf("Failed to read formulas: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "formulas.csv")
+	if err := os.WriteFile(path, []byte(replace(string(data))), 0o644); err != nil {
+		t.Fatalf("Failed to write formulas: %v", err)
+	}
+	return path
+}
+
+func TestRun_Lint(t *testing.T) {
+	good := writeFormulas(t, func(s string) string { return s })
+	bad := writeFormulas(t, func(s string) string { return strings.Replace(s, "0.3347309", "abc", 1) })
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"lint", good, bad}, nil, &stdout, &stderr); code != exitValidation {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitValidation, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), good+": ok, 6 formulas") {
+		t.Errorf("Expected %s to pass, got:\n%s", good, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), bad+`: row 2, column formula_age_linear_coefficient: invalid number "abc"`) {
+		t.Errorf("Expected a problem for %s, got:\n%s", bad, stdout.String())
+	}
+}
+
+func TestRun_Diff(t *testing.T) {
+	old := writeFormulas(t, func(s string) string { return s })
+	changed := writeFormulas(t, func(s string) string { return strings.Replace(s, "-6.8392144", "-6.7392144", 1) })
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"diff", "-format", "json", old, changed}, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+
+	var diff calculator.StoreDiff
+	if err := json.Unmarshal(stdout.Bytes(), &diff); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if len(diff.Formulas) != 1 || diff.Formulas[0].FormulaID != "1-3" {
+		t.Errorf("Expected formula 1-3 to change, got %+v", diff.Formulas)
+	}
+	if diff.Impact.MaxChange <= 0 {
+		t.Errorf("Expected an impact, got %+v", diff.Impact)
+	}
+}
+
+func TestRun_DiffRemovedFormula(t *testing.T) {
+	old := writeFormulas(t, func(s string) string { return s })
+
+	// A CSV must have every formula, so the formula is removed from the JSON
+	var converted, stderr bytes.Buffer
+	if code := run([]string{"convert", old}, nil, &converted, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	var file map[string][]json.RawMessage
+	if err := json.Unmarshal(converted.Bytes(), &file); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	file["formulas"] = file["formulas"][:len(file["formulas"])-1]
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatalf("Failed to encode formulas: %v", err)
+	}
+	removed := filepath.Join(t.TempDir(), "formulas.json")
+	if err := os.WriteFile(removed, data, 0o644); err != nil {
+		t.Fatalf("Failed to write formulas: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	if code := run([]string{"diff", old, removed}, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "168 patients can no longer be calculated, first: age 20, BMI 18, egg source donor") {
+		t.Errorf("Expected the patients without a formula to be reported, got:\n%s", stdout.String())
+	}
+}
+
+func TestRun_Convert(t *testing.T) {
+	csvPath := writeFormulas(t, func(s string) string { return s })
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"convert", csvPath}, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	jsonPath := filepath.Join(t.TempDir(), "formulas.json")
+	if err := os.WriteFile(jsonPath, stdout.Bytes(), 0o644); err != nil {
+		t.Fatalf("Failed to write formulas: %v", err)
+	}
+
+	// The converted formulas give the same result as the CSV
+	for _, path := range []string{csvPath, jsonPath} {
+		var out bytes.Buffer
+		if code := run(append(scenario1Args, "-formulas", path), nil, &out, &stderr); code != exitOK {
+			t.Fatalf("Expected exit code %d for %s, got %d: %s", exitOK, path, code, stderr.String())
+		}
+		if !strings.HasPrefix(out.String(), "Cumulative chance of live birth: 62.21%\nFormula: 1-3") {
+			t.Errorf("Unexpected output for %s:\n%s", path, out.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"diff", csvPath, jsonPath}, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "No formula changes\n") {
+		t.Errorf("Expected no changes between the CSV and its conversion, got:\n%s", stdout.String())
+	}
+}
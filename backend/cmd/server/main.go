@@ -1,21 +1,114 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"ivf-calculator-backend/internal/batch"
+	"ivf-calculator-backend/internal/calculator"
 	"ivf-calculator-backend/internal/http/handlers"
 )
 
+// defaultBatchMaxSize is the default limit on items per batch request
+const defaultBatchMaxSize = 1000
+
 func main() {
+	formulasPath := os.Getenv("FORMULAS_PATH")
+
+	// Stat the formula file before loading it so the reloader notices changes
+	// made while it loads
+	var formulasInfo os.FileInfo
+	if formulasPath != "" {
+		info, err := os.Stat(formulasPath)
+		if err != nil {
+			log.Fatalf("Failed to load formulas: %v", err)
+		}
+		formulasInfo = info
+	}
+
+	store, err := loadFormulaStore(formulasPath)
+	if err != nil {
+		logLoadProblems(err)
+		log.Fatalf("Failed to load formulas: %v", err)
+	}
+	if formulasPath == "" {
+		log.Printf("Loaded %d formulas from embedded CSV (checksum %s)", store.Len(), store.Checksum())
+	} else {
+		log.Printf("Loaded %d formulas from %s (checksum %s)", store.Len(), formulasPath, store.Checksum())
+	}
+
+	archived, err := loadArchivedFormulaStores(os.Getenv("FORMULAS_ARCHIVE_DIR"))
+	if err != nil {
+		logLoadProblems(err)
+		log.Fatalf("Failed to load archived formulas: %v", err)
+	}
+
+	calc := calculator.New(store, archived...)
+	for _, version := range calc.Versions() {
+		log.Printf("Formula version %s: %d formulas (checksum %s, latest %t)",
+			version.Version, version.Formulas, version.Checksum, version.Latest)
+	}
+	if model := os.Getenv("PREDICTION_MODEL"); model != "" {
+		if err := calc.SetDefaultModel(model); err != nil {
+			log.Fatalf("Invalid PREDICTION_MODEL: %v", err)
+		}
+	}
+	if level := os.Getenv("CONFIDENCE_LEVEL"); level != "" {
+		value, err := strconv.ParseFloat(level, 64)
+		if err == nil {
+			err = calc.SetConfidenceLevel(value)
+		}
+		if err != nil {
+			log.Fatalf("Invalid CONFIDENCE_LEVEL: %v", err)
+		}
+	}
+	if rounding := os.Getenv("ROUNDING"); rounding != "" {
+		if err := calc.SetRounding(rounding); err != nil {
+			log.Fatalf("Invalid ROUNDING: %v", err)
+		}
+	}
+	calculateHandler := handlers.NewCalculateHandler(calc)
+
+	// Watch the formula file for changes; the embedded CSV cannot change
+	if formulasPath != "" {
+		interval, err := reloadInterval(os.Getenv("FORMULAS_RELOAD_INTERVAL"))
+		if err != nil {
+			log.Fatalf("Invalid FORMULAS_RELOAD_INTERVAL: %v", err)
+		}
+		retained, err := envInt("FORMULAS_RETAINED_VERSIONS", calculator.DefaultRetainedVersions, 0)
+		if err == nil {
+			err = calc.SetRetainedVersions(retained)
+		}
+		if err != nil {
+			log.Fatalf("Invalid FORMULAS_RETAINED_VERSIONS: %v", err)
+		}
+		go newFormulaReloader(formulasPath, calc, formulasInfo).run(interval)
+	}
+
+	batchWorkers, err := envInt("BATCH_WORKERS", runtime.NumCPU(), 1)
+	if err != nil {
+		log.Fatalf("Invalid BATCH_WORKERS: %v", err)
+	}
+	batchMaxSize, err := envInt("BATCH_MAX_SIZE", defaultBatchMaxSize, 1)
+	if err != nil {
+		log.Fatalf("Invalid BATCH_MAX_SIZE: %v", err)
+	}
+	batchHandler := handlers.NewBatchHandler(batch.NewProcessor(calc, batchWorkers), batchMaxSize)
+
 	r := gin.Default()
 
 	// CORS middleware - allow frontend origin
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept-Language")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
 
 		if c.Request.Method == http.MethodOptions {
@@ -28,13 +121,27 @@ func main() {
 
 	// Health check endpoint
 	r.GET("/healthz", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		c.JSON(http.StatusOK, gin.H{
+			"status":          "ok",
+			"modelVersion":    calc.Store().Version(),
+			"formulaChecksum": calc.Store().Checksum(),
+		})
 	})
 
 	// API routes
 	api := r.Group("/api")
 	{
-		api.POST("/calculate", handlers.PostCalculate)
+		api.GET("/openapi.json", calculateHandler.GetOpenAPI)
+		api.POST("/calculate", calculateHandler.PostCalculate)
+		api.GET("/calculate/versions", calculateHandler.GetVersions)
+		api.GET("/calculate/models", calculateHandler.GetModels)
+		api.GET("/calculate/reasons", handlers.GetReasons)
+		api.GET("/calculate/schema", calculateHandler.GetSchema)
+		api.POST("/calculate/compare", calculateHandler.PostCompare)
+		api.POST("/calculate/curve", calculateHandler.PostCurve)
+		api.POST("/calculate/simulate", calculateHandler.PostSimulate)
+		api.POST("/calculate/batch", batchHandler.PostBatch)
+		api.POST("/calculate/batch/csv", batchHandler.PostBatchCSV)
 	}
 
 	port := os.Getenv("PORT")
@@ -47,3 +154,68 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// loadFormulaStore loads formulas from path, or from the CSV embedded in the
+// binary when path is empty
+func loadFormulaStore(path string) (*calculator.FormulaStore, error) {
+	if path == "" {
+		return calculator.DefaultFormulaStore()
+	}
+	return calculator.LoadFormulasFile(path)
+}
+
+// loadArchivedFormulaStores loads every CSV and JSON formula file in dir as a
+// prior formula release, named after the file without its extension (e.g.
+// 2023-10.csv is "2023-10")
+func loadArchivedFormulaStores(dir string) ([]*calculator.FormulaStore, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	var paths []string
+	for _, pattern := range []string{"*.csv", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+
+	stores := make([]*calculator.FormulaStore, 0, len(paths))
+	for _, path := range paths {
+		store, err := calculator.LoadFormulasFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		version := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		stores = append(stores, store.WithVersion(version))
+	}
+	return stores, nil
+}
+
+// envInt reads an integer of at least minimum from the environment, or
+// returns def when unset
+func envInt(name string, def, minimum int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < minimum {
+		return 0, fmt.Errorf("must be at least %d, got %d", minimum, n)
+	}
+	return n, nil
+}
+
+// logLoadProblems logs each problem from a formula file load report
+func logLoadProblems(err error) {
+	var report *calculator.LoadReport
+	if errors.As(err, &report) {
+		for _, problem := range report.Problems {
+			log.Printf("Formula CSV: %s", problem)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"ivf-calculator-backend/internal/calculator"
+)
+
+// defaultReloadInterval is how often the formula file is checked for changes
+const defaultReloadInterval = 30 * time.Second
+
+// formulaReloader swaps freshly loaded formulas into a Calculator when the
+// formula file changes or the process receives SIGHUP. A file that fails
+// validation is logged and the current formulas are kept.
+type formulaReloader struct {
+	path    string
+	calc    *calculator.Calculator
+	modTime time.Time
+	size    int64
+}
+
+// newFormulaReloader watches path, whose formulas calc is using. info is the
+// file as it was stat'ed before those formulas were loaded, so a change made
+// while they were loading is picked up by the first check.
+func newFormulaReloader(path string, calc *calculator.Calculator, info os.FileInfo) *formulaReloader {
+	return &formulaReloader{path: path, calc: calc, modTime: info.ModTime(), size: info.Size()}
+}
+
+// reloadInterval parses FORMULAS_RELOAD_INTERVAL; 0 disables polling so only
+// SIGHUP triggers a reload
+func reloadInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultReloadInterval, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func (r *formulaReloader) run(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-hup:
+			r.changed()
+			r.reload("SIGHUP")
+		case <-tick:
+			if r.changed() {
+				r.reload("file change")
+			}
+		}
+	}
+}
+
+// changed reports whether the file's modification time or size differ from
+// the last check
+func (r *formulaReloader) changed() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		log.Printf("Failed to stat formula file %s: %v", r.path, err)
+		return false
+	}
+	if info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false
+	}
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return true
+}
+
+func (r *formulaReloader) reload(trigger string) {
+	store, err := calculator.LoadFormulasFile(r.path)
+	if err != nil {
+		logLoadProblems(err)
+		log.Printf("Formula reload (%s) failed, keeping version %s: %v", trigger, r.calc.Store().Version(), err)
+		return
+	}
+
+	previous := r.calc.Store().Checksum()
+	if store.Checksum() == previous {
+		log.Printf("Formula reload (%s): %s unchanged (checksum %s)", trigger, r.path, previous)
+		return
+	}
+
+	if err := r.calc.SetStore(store); err != nil {
+		log.Printf("Formula reload (%s) failed, keeping version %s: %v", trigger, r.calc.Store().Version(), err)
+		return
+	}
+	log.Printf("Formula reload (%s): loaded %d formulas from %s as version %s (checksum %s, previously %s)",
+		trigger, store.Len(), r.path, store.Version(), store.Checksum(), previous)
+}
@@ -0,0 +1,93 @@
+// Package batch validates and calculates many patients at once.
+package batch
+
+import (
+	"context"
+	"sync"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/validation"
+)
+
+// Item is one patient in a batch, identified by a caller-supplied id
+type Item struct {
+	ID string `json:"id"`
+	calculator.CalculateRequest
+}
+
+// Result is the outcome for one Item. Errors holds validation errors and
+// Error any other failure; Result is only set when both are empty, and then
+// Warnings holds any caveats of the item's inputs.
+type Result struct {
+	ID       string                        `json:"id"`
+	Result   *calculator.CalculateResponse `json:"result,omitempty"`
+	Warnings validation.Warnings           `json:"warnings,omitempty"`
+	Errors   validation.Errors             `json:"errors,omitempty"`
+	Error    string                        `json:"error,omitempty"`
+}
+
+// Processor validates and calculates items with a bounded number of workers
+type Processor struct {
+	calc    *calculator.Calculator
+	workers int
+}
+
+// NewProcessor creates a Processor that runs at most workers calculations at once
+func NewProcessor(calc *calculator.Calculator, workers int) *Processor {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Processor{calc: calc, workers: workers}
+}
+
+// Process validates and calculates a single item
+func (p *Processor) Process(ctx context.Context, item Item) Result {
+	result := Result{ID: item.ID}
+
+	if errors := validation.ValidateCalculateRequest(item.CalculateRequest, p.calc.MaxRetrievals()); len(errors) > 0 {
+		result.Errors = errors
+		return result
+	}
+
+	response, err := p.calc.Calculate(ctx, item.CalculateRequest)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// The breakdown is only returned by the single calculate endpoint
+	response.Breakdown = nil
+	result.Result = &response
+	result.Warnings = validation.WarnCalculateRequest(item.CalculateRequest)
+	return result
+}
+
+// Run processes items concurrently and returns their results in the same
+// order. Items not started before ctx is done get ctx's error.
+func (p *Processor) Run(ctx context.Context, items []Item) []Result {
+	results := make([]Result, len(items))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(p.workers, len(items)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = p.Process(ctx, items[i])
+			}
+		}()
+	}
+
+	for i := range items {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			results[i] = Result{ID: items[i].ID, Error: ctx.Err().Error()}
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
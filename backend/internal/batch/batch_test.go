@@ -0,0 +1,96 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/validation"
+)
+
+func testProcessor(t *testing.T, workers int) *Processor {
+	t.Helper()
+	store, err := calculator.DefaultFormulaStore()
+	if err != nil {
+		t.Fatalf("Failed to load formulas: %v", err)
+	}
+	return NewProcessor(calculator.New(store), workers)
+}
+
+func validItem(id string) Item {
+	return Item{
+		ID: id,
+		CalculateRequest: calculator.CalculateRequest{
+			Age:              32,
+			WeightLbs:        141,
+			HeightFt:         5,
+			HeightIn:         6,
+			PriorIvfCycles:   "no",
+			PriorPregnancies: 1,
+			PriorBirths:      1,
+			Reasons:          []string{"endometriosis", "ovulatory_disorder"},
+			EggSource:        "own",
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	var items []Item
+	for i := 0; i < 50; i++ {
+		item := validItem(fmt.Sprintf("patient-%d", i))
+		if i%10 == 0 {
+			item.Age = 60
+		}
+		items = append(items, item)
+	}
+
+	results := testProcessor(t, 4).Run(context.Background(), items)
+
+	if len(results) != len(items) {
+		t.Fatalf("Expected %d results, got %d", len(items), len(results))
+	}
+	for i, result := range results {
+		if result.ID != items[i].ID {
+			t.Errorf("Result %d has id %s, want %s", i, result.ID, items[i].ID)
+		}
+		if i%10 == 0 {
+			if errs := result.Errors.Field("age"); len(errs) != 1 || errs[0].Code != validation.CodeOutOfRange || result.Result != nil {
+				t.Errorf("Expected age validation error for %s, got %+v", result.ID, result)
+			}
+			continue
+		}
+		if result.Result == nil || result.Result.CumulativeChancePercent != 62.21 {
+			t.Errorf("Expected 62.21 for %s, got %+v", result.ID, result)
+		}
+		if result.Result != nil && result.Result.Breakdown != nil {
+			t.Errorf("Expected no breakdown for %s", result.ID)
+		}
+		if result.Warnings != nil {
+			t.Errorf("Expected no warnings for %s, got %+v", result.ID, result.Warnings)
+		}
+	}
+}
+
+func TestProcess_Warnings(t *testing.T) {
+	item := validItem("older")
+	item.Age = 47
+
+	result := testProcessor(t, 1).Process(context.Background(), item)
+
+	if result.Result == nil || len(result.Warnings) != 1 || result.Warnings[0].Code != validation.WarningAgeOwnEggs {
+		t.Errorf("Expected a result with an age warning, got %+v", result)
+	}
+}
+
+func TestRun_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	items := []Item{validItem("a"), validItem("b")}
+	for _, result := range testProcessor(t, 1).Run(ctx, items) {
+		if result.Result == nil && result.Error != context.Canceled.Error() {
+			t.Errorf("Expected a result or a canceled error, got %+v", result)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package batch
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/validation"
+)
+
+// ErrInvalidCSV is returned by ProcessCSV when the header cannot be used.
+// Nothing has been written to the output when it is returned.
+var ErrInvalidCSV = errors.New("invalid CSV")
+
+// ReasonSeparator separates multiple reasons within the CSV reasons column
+const ReasonSeparator = ";"
+
+// Columns appended to every output row
+const (
+	ColumnResult       = "cumulativeChancePercent"
+	ColumnFormulaID    = "formulaId"
+	ColumnModelVersion = "modelVersion"
+	ColumnErrors       = "errors"
+)
+
+// requiredCSVColumns must be present in the header of an input CSV. The other
+// CalculateRequest fields are optional columns named after their JSON keys.
+var requiredCSVColumns = []string{"age", "eggSource", "reasons"}
+
+// csvRowsPerChunk is how many rows per worker are read before results are
+// written, bounding memory use regardless of file size
+const csvRowsPerChunk = 16
+
+// ProcessCSV reads patient rows from r and writes them to w with result,
+// formula id, model version and error columns appended. Columns are named
+// after the CalculateRequest JSON fields plus an optional "id"; unknown
+// columns are passed through unchanged. A row with more or fewer fields than
+// the header is reported as an error and written at the header's width.
+// Rows are processed in chunks so large files are streamed rather than held
+// in memory. When the input cannot be read past some row, the rows before it
+// have already been written, so a final row reporting the error in the errors
+// column ends the output before the error is returned.
+func (p *Processor) ProcessCSV(ctx context.Context, r io.Reader, w io.Writer) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("%w: missing header", ErrInvalidCSV)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+	}
+
+	colIndex := make(map[string]int)
+	for i, col := range header {
+		colIndex[strings.TrimSpace(col)] = i
+	}
+	for _, col := range requiredCSVColumns {
+		if _, ok := colIndex[col]; !ok {
+			return fmt.Errorf("%w: missing required column %q", ErrInvalidCSV, col)
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append(header, ColumnResult, ColumnFormulaID, ColumnModelVersion, ColumnErrors)); err != nil {
+		return err
+	}
+
+	chunkSize := p.workers * csvRowsPerChunk
+	for {
+		records, items, parseErrors, readErr := readCSVChunk(reader, len(header), colIndex, chunkSize)
+
+		// Only rows that parsed are calculated
+		parsed := make([]Item, 0, len(items))
+		for i, item := range items {
+			if len(parseErrors[i]) == 0 {
+				parsed = append(parsed, item)
+			}
+		}
+		results := p.Run(ctx, parsed)
+
+		next := 0
+		for i, record := range records {
+			var result Result
+			if len(parseErrors[i]) == 0 {
+				result = results[next]
+				next++
+			}
+			if err := writer.Write(append(record, resultColumns(result, parseErrors[i])...)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return writeReadError(writer, len(header), readErr)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// readCSVChunk reads up to size records, padded or cut to width fields.
+// Records whose cells could not be parsed are still returned, with their
+// problems in parseErrors, so that the output keeps one row per input row.
+func readCSVChunk(reader *csv.Reader, width int, colIndex map[string]int, size int) (records [][]string, items []Item, parseErrors []validation.Errors, err error) {
+	for len(records) < size {
+		record, err := reader.Read()
+		if err != nil {
+			return records, items, parseErrors, err
+		}
+
+		var item Item
+		var problems validation.Errors
+		if len(record) == width {
+			item, problems = parseCSVItem(record, colIndex)
+		} else {
+			// The cells of a ragged row cannot be trusted to be under their columns
+			problems = validation.Errors{
+				validation.NewError("", validation.CodeFieldCount, map[string]any{"fields": len(record), "expected": width}),
+			}
+			if len(record) < width {
+				record = append(record, make([]string, width-len(record))...)
+			}
+			record = record[:width]
+		}
+		records = append(records, record)
+		items = append(items, item)
+		parseErrors = append(parseErrors, problems)
+	}
+	return records, items, parseErrors, nil
+}
+
+// writeReadError ends the output with a row of width empty cells whose errors
+// column reports err, so a client can tell the output was cut short
+func writeReadError(writer *csv.Writer, width int, err error) error {
+	row := append(make([]string, width), "", "", "", "error: "+err.Error()+"; the rows after it were not read")
+	if writeErr := writer.Write(row); writeErr != nil {
+		return writeErr
+	}
+	writer.Flush()
+	if writeErr := writer.Error(); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// parseCSVItem converts a CSV record into an Item
+func parseCSVItem(record []string, colIndex map[string]int) (Item, validation.Errors) {
+	var problems validation.Errors
+	cell := func(col string) string {
+		i, ok := colIndex[col]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	intCell := func(col string) int {
+		value := cell(col)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			problems = append(problems, validation.NewError(col, validation.CodeInvalidType, map[string]any{"expected": "integer", "got": value}))
+		}
+		return n
+	}
+	floatCell := func(col string) float64 {
+		value := cell(col)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			problems = append(problems, validation.NewError(col, validation.CodeInvalidType, map[string]any{"expected": "number", "got": value}))
+		}
+		return n
+	}
+
+	var reasons []string
+	for _, reason := range strings.Split(cell("reasons"), ReasonSeparator) {
+		if reason = strings.TrimSpace(reason); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	item := Item{
+		ID: cell("id"),
+		CalculateRequest: calculator.CalculateRequest{
+			Age:              intCell("age"),
+			WeightLbs:        intCell("weightLbs"),
+			HeightFt:         intCell("heightFt"),
+			HeightIn:         intCell("heightIn"),
+			WeightKg:         floatCell("weightKg"),
+			HeightCm:         floatCell("heightCm"),
+			BMI:              floatCell("bmi"),
+			PriorIvfCycles:   cell("priorIvfCycles"),
+			PriorPregnancies: intCell("priorPregnancies"),
+			PriorBirths:      intCell("priorBirths"),
+			Reasons:          reasons,
+			EggSource:        cell("eggSource"),
+			Retrievals:       intCell("retrievals"),
+			Model:            cell("model"),
+			ModelVersion:     cell("modelVersion"),
+			ConfidenceLevel:  floatCell("confidenceLevel"),
+			Rounding:         cell("rounding"),
+		},
+	}
+	return item, problems
+}
+
+// resultColumns formats a result as the appended output columns. Rows that
+// failed to parse were not calculated and only report those problems.
+func resultColumns(result Result, parseErrors validation.Errors) []string {
+	if len(parseErrors) > 0 {
+		return []string{"", "", "", parseErrors.Error()}
+	}
+	if result.Result == nil {
+		if result.Error != "" {
+			return []string{"", "", "", "error: " + result.Error}
+		}
+		return []string{"", "", "", result.Errors.Error()}
+	}
+	return []string{
+		strconv.FormatFloat(result.Result.CumulativeChancePercent, 'f', -1, 64),
+		result.Result.FormulaID,
+		result.Result.ModelVersion,
+		"",
+	}
+}
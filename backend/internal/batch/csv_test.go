@@ -0,0 +1,165 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"ivf-calculator-backend/internal/calculator"
+)
+
+func TestProcessCSV(t *testing.T) {
+	input := strings.Join([]string{
+		"id,age,weightLbs,heightFt,heightIn,priorIvfCycles,priorPregnancies,priorBirths,reasons,eggSource,clinic",
+		"p1,32,141,5,6,no,1,1,endometriosis;ovulatory_disorder,own,north",
+		"p2,60,141,5,6,no,1,1,unknown,own,south",
+		"p3,abc,141,5,6,no,1,1,unknown,own,east",
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := testProcessor(t, 2).ProcessCSV(context.Background(), strings.NewReader(input), &out); err != nil {
+		t.Fatalf("ProcessCSV returned error: %v", err)
+	}
+
+	version := testProcessor(t, 1).calc.Store().Version()
+	want := strings.Join([]string{
+		"id,age,weightLbs,heightFt,heightIn,priorIvfCycles,priorPregnancies,priorBirths,reasons,eggSource,clinic,cumulativeChancePercent,formulaId,modelVersion,errors",
+		"p1,32,141,5,6,no,1,1,endometriosis;ovulatory_disorder,own,north,62.21,1-3," + version + ",",
+		"p2,60,141,5,6,no,1,1,unknown,own,south,,,,age: must be between 20 and 50",
+		"p3,abc,141,5,6,no,1,1,unknown,own,east,,,,age: must be of type integer",
+	}, "\n") + "\n"
+
+	if out.String() != want {
+		t.Errorf("ProcessCSV output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestProcessCSV_ManyRows(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("age,bmi,priorPregnancies,priorBirths,reasons,eggSource\n")
+	for i := 0; i < 1000; i++ {
+		input.WriteString("32,22.8,0,0,unknown,donor\n")
+	}
+
+	var out bytes.Buffer
+	if err := testProcessor(t, 4).ProcessCSV(context.Background(), strings.NewReader(input.String()), &out); err != nil {
+		t.Fatalf("ProcessCSV returned error: %v", err)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 1001 {
+		t.Errorf("Expected 1001 output lines, got %d", lines)
+	}
+}
+
+func TestProcessCSV_MissingColumn(t *testing.T) {
+	var out bytes.Buffer
+	err := testProcessor(t, 1).ProcessCSV(context.Background(), strings.NewReader("age,reasons\n32,unknown\n"), &out)
+	if !errors.Is(err, ErrInvalidCSV) {
+		t.Errorf("Expected ErrInvalidCSV, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output, got %q", out.String())
+	}
+}
+
+func TestProcessCSV_RaggedRows(t *testing.T) {
+	input := strings.Join([]string{
+		"id,age,bmi,priorPregnancies,priorBirths,reasons,eggSource,clinic,extra",
+		"short,32,22.8,0,0,unknown,donor,north",
+		"long,32,22.8,0,0,unknown,donor,north,x,y",
+		"ok,32,22.8,0,0,unknown,donor,north,x",
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := testProcessor(t, 1).ProcessCSV(context.Background(), strings.NewReader(input), &out); err != nil {
+		t.Fatalf("ProcessCSV returned error: %v", err)
+	}
+
+	version := testProcessor(t, 1).calc.Store().Version()
+	want := strings.Join([]string{
+		"id,age,bmi,priorPregnancies,priorBirths,reasons,eggSource,clinic,extra,cumulativeChancePercent,formulaId,modelVersion,errors",
+		"short,32,22.8,0,0,unknown,donor,north,,,,,\"row has 8 fields, the header has 9\"",
+		"long,32,22.8,0,0,unknown,donor,north,x,,,,\"row has 10 fields, the header has 9\"",
+		"ok,32,22.8,0,0,unknown,donor,north,x,56.8,14-16," + version + ",",
+	}, "\n") + "\n"
+
+	if out.String() != want {
+		t.Errorf("ProcessCSV output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestProcessCSV_BadRowAfterStreaming(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("id,age,bmi,priorPregnancies,priorBirths,reasons,eggSource\n")
+	for i := 0; i < 2*csvRowsPerChunk; i++ {
+		input.WriteString("ok,32,22.8,0,0,unknown,donor\n")
+	}
+	input.WriteString("bad,3\"2,22.8,0,0,unknown,donor\n")
+	input.WriteString("after,32,22.8,0,0,unknown,donor\n")
+
+	var out bytes.Buffer
+	if err := testProcessor(t, 1).ProcessCSV(context.Background(), strings.NewReader(input.String()), &out); err == nil {
+		t.Fatal("Expected an error for the bad quote")
+	}
+
+	// The rows before the bad one are written, then a row reporting the error
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2*csvRowsPerChunk+2 {
+		t.Fatalf("Expected %d output lines, got %d:\n%s", 2*csvRowsPerChunk+2, len(lines), out.String())
+	}
+	want := `,,,,,,,,,,"error: parse error on line 34, column 6: bare "" in non-quoted-field; the rows after it were not read"`
+	if last := lines[len(lines)-1]; last != want {
+		t.Errorf("Last line = %s, want %s", last, want)
+	}
+}
+
+// countingModel predicts a fixed chance and counts its predictions
+type countingModel struct {
+	predictions *atomic.Int64
+}
+
+func (m countingModel) Info() calculator.ModelInfo {
+	return calculator.ModelInfo{Name: "counting", Version: "1", Description: "counts predictions", Covariates: []string{}}
+}
+
+func (m countingModel) Predict(ctx context.Context, patient calculator.Patient) (calculator.Prediction, error) {
+	m.predictions.Add(1)
+	return calculator.Prediction{Probability: 0.5}, nil
+}
+
+func TestProcessCSV_ModelColumns(t *testing.T) {
+	processor := testProcessor(t, 2)
+	model := countingModel{predictions: new(atomic.Int64)}
+	if err := processor.calc.RegisterModel(model); err != nil {
+		t.Fatalf("RegisterModel returned error: %v", err)
+	}
+
+	input := strings.Join([]string{
+		"id,age,bmi,reasons,eggSource,model,confidenceLevel",
+		"p1,32,22.8,unknown,donor,counting,0.9",
+		"p2,32,22.8,unknown,donor,counting,high",
+		"p3,32,22.8,unknown,donor,cdc,",
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := processor.ProcessCSV(context.Background(), strings.NewReader(input), &out); err != nil {
+		t.Fatalf("ProcessCSV returned error: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"id,age,bmi,reasons,eggSource,model,confidenceLevel,cumulativeChancePercent,formulaId,modelVersion,errors",
+		"p1,32,22.8,unknown,donor,counting,0.9,50,,1,",
+		"p2,32,22.8,unknown,donor,counting,high,,,,confidenceLevel: must be of type number",
+		"p3,32,22.8,unknown,donor,cdc,,56.8,14-16," + processor.calc.Store().Version() + ",",
+	}, "\n") + "\n"
+
+	if out.String() != want {
+		t.Errorf("ProcessCSV output =\n%s\nwant\n%s", out.String(), want)
+	}
+	// The row whose confidence level failed to parse is not calculated
+	if n := model.predictions.Load(); n != 1 {
+		t.Errorf("Expected 1 prediction, got %d", n)
+	}
+}
@@ -1,329 +1,322 @@
 package calculator
 
 import (
-	"encoding/csv"
+	"context"
 	"fmt"
-	"io"
 	"math"
-	"os"
-	"path/filepath"
-	"runtime"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // CalculateRequest represents the request body for the calculate endpoint
 type CalculateRequest struct {
 	Age              int      `json:"age" binding:"required"`
-	WeightLbs        int      `json:"weightLbs" binding:"required"`
-	HeightFt         int      `json:"heightFt" binding:"required"`
-	HeightIn         int      `json:"heightIn" binding:"gte=0"`
-	PriorIvfCycles   string	  `json:"priorIvfCycles""`
+	WeightLbs        int      `json:"weightLbs,omitempty"`
+	HeightFt         int      `json:"heightFt,omitempty"`
+	HeightIn         int      `json:"heightIn,omitempty" binding:"gte=0"`
+	WeightKg         float64  `json:"weightKg,omitempty"`
+	HeightCm         float64  `json:"heightCm,omitempty"`
+	BMI              float64  `json:"bmi,omitempty"`
+	PriorIvfCycles   string   `json:"priorIvfCycles"`
 	PriorPregnancies int      `json:"priorPregnancies" binding:"gte=0"`
 	PriorBirths      int      `json:"priorBirths" binding:"gte=0"`
 	Reasons          []string `json:"reasons" binding:"required"`
 	EggSource        string   `json:"eggSource" binding:"required"`
+	Retrievals       int      `json:"retrievals,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	ModelVersion     string   `json:"modelVersion,omitempty"`
+	ConfidenceLevel  float64  `json:"confidenceLevel,omitempty"`
+	Rounding         string   `json:"rounding,omitempty"`
 }
 
-// CalculateResponse represents the response from the calculate endpoint
-type CalculateResponse struct {
+// retrievalCount returns the number of intended retrievals, defaulting to one
+func (req CalculateRequest) retrievalCount() int {
+	if req.Retrievals == 0 {
+		return 1
+	}
+	return req.Retrievals
+}
+
+// RetrievalChance is the cumulative chance after a number of intended retrievals
+type RetrievalChance struct {
+	Retrievals              int     `json:"retrievals"`
 	CumulativeChancePercent float64 `json:"cumulativeChancePercent"`
+	Probability             float64 `json:"probability"`
+}
+
+// CalculateResponse represents the response from the calculate endpoint.
+// Probability is the unrounded chance the percentages are rounded from by the
+// Rounding policy.
+type CalculateResponse struct {
+	CumulativeChancePercent float64             `json:"cumulativeChancePercent"`
+	Probability             float64             `json:"probability"`
+	Rounding                string              `json:"rounding"`
+	ChancesByRetrieval      []RetrievalChance   `json:"chancesByRetrieval,omitempty"`
+	FormulaID               string              `json:"formulaId,omitempty"`
+	Model                   string              `json:"model,omitempty"`
+	ModelVersion            string              `json:"modelVersion,omitempty"`
+	FormulaChecksum         string              `json:"formulaChecksum,omitempty"`
+	ConfidenceInterval      *ConfidenceInterval `json:"confidenceInterval,omitempty"`
+	Breakdown               *Breakdown          `json:"breakdown,omitempty"`
+}
+
+// Term is a single additive contribution to the logit. Value is the input the
+// coefficient multiplies (1 for intercept and categorical terms).
+type Term struct {
+	Name         string  `json:"name"`
+	Coefficient  float64 `json:"coefficient"`
+	Value        float64 `json:"value"`
+	Contribution float64 `json:"contribution"`
+}
+
+// Breakdown explains how a result was derived from the selected formula
+type Breakdown struct {
+	FormulaID   string  `json:"formulaId"`
+	BMI         float64 `json:"bmi"`
+	Terms       []Term  `json:"terms"`
+	Logit       float64 `json:"logit"`
+	Probability float64 `json:"probability"`
+	// LogitStandardError is set when the formula has coefficient covariance
+	LogitStandardError *float64 `json:"logitStandardError,omitempty"`
+
+	maxRetrievals int
 }
 
 // Formula represents a CDC formula with all its coefficients
 type Formula struct {
-	UsingOwnEggs                bool
-	AttemptedIVFPreviously      *bool // nil means N/A (donor eggs)
-	IsReasonKnown               bool
-	CDCFormula                  string
-	Intercept                   float64
-	AgeLinearCoeff              float64
-	AgePowerCoeff               float64
-	AgePowerFactor              float64
-	BMILinearCoeff              float64
-	BMIPowerCoeff               float64
-	BMIPowerFactor              float64
-	TubalFactorTrue             float64
-	TubalFactorFalse            float64
-	MaleFactorInfertilityTrue   float64
-	MaleFactorInfertilityFalse  float64
-	EndometriosisTrue           float64
-	EndometriosisFalse          float64
-	OvulatoryDisorderTrue       float64
-	OvulatoryDisorderFalse      float64
+	UsingOwnEggs                  bool
+	AttemptedIVFPreviously        *bool // nil means N/A (donor eggs)
+	IsReasonKnown                 bool
+	CDCFormula                    string
+	Intercept                     float64
+	AgeLinearCoeff                float64
+	AgePowerCoeff                 float64
+	AgePowerFactor                float64
+	BMILinearCoeff                float64
+	BMIPowerCoeff                 float64
+	BMIPowerFactor                float64
+	TubalFactorTrue               float64
+	TubalFactorFalse              float64
+	MaleFactorInfertilityTrue     float64
+	MaleFactorInfertilityFalse    float64
+	EndometriosisTrue             float64
+	EndometriosisFalse            float64
+	OvulatoryDisorderTrue         float64
+	OvulatoryDisorderFalse        float64
 	DiminishedOvarianReserveTrue  float64
 	DiminishedOvarianReserveFalse float64
-	UterineFactorTrue           float64
-	UterineFactorFalse          float64
-	OtherReasonTrue             float64
-	OtherReasonFalse            float64
-	UnexplainedInfertilityTrue  float64
-	UnexplainedInfertilityFalse float64
-	PriorPregnancies0           float64
-	PriorPregnancies1           float64
-	PriorPregnancies2Plus       float64
-	PriorLiveBirths0            float64
-	PriorLiveBirths1            float64
-	PriorLiveBirths2Plus        float64
+	UterineFactorTrue             float64
+	UterineFactorFalse            float64
+	OtherReasonTrue               float64
+	OtherReasonFalse              float64
+	UnexplainedInfertilityTrue    float64
+	UnexplainedInfertilityFalse   float64
+	PriorPregnancies0             float64
+	PriorPregnancies1             float64
+	PriorPregnancies2Plus         float64
+	PriorLiveBirths0              float64
+	PriorLiveBirths1              float64
+	PriorLiveBirths2Plus          float64
+	// RetrievalAdjustments holds the logit adjustment for 1, 2, ... intended
+	// retrievals. Nil means the formula only estimates a single retrieval.
+	RetrievalAdjustments []float64
 }
 
-var formulas []Formula
-
-// init loads the formulas from CSV on package initialization
-func init() {
-	if err := loadFormulas(); err != nil {
-		fmt.Errorf("Failed to load formulas: %v", err)
+// MaxRetrievals returns the highest number of intended retrievals the formula
+// can estimate
+func (f *Formula) MaxRetrievals() int {
+	if len(f.RetrievalAdjustments) == 0 {
+		return 1
 	}
+	return len(f.RetrievalAdjustments)
 }
 
-// loadFormulas reads and parses the CSV file containing IVF success formulas
-func loadFormulas() error {
-	csvPath := getCSVPath()
-
-	file, err := os.Open(csvPath)
-	if err != nil {
-		return fmt.Errorf("failed to open CSV file: %w", err)
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	
-	// Read header
-	header, err := reader.Read()
-	if err != nil {
-		return fmt.Errorf("failed to read header: %w", err)
-	}
-
-	// Create a map of column indices
-	colIndex := make(map[string]int)
-	for i, col := range header {
-		colIndex[strings.TrimSpace(col)] = i
-	}
-
-	formulas = []Formula{}
-	
-	// Read data rows
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read CSV record: %w", err)
-		}
+// Calculator evaluates CDC formulas from a set of versioned FormulaStores. The
+// latest store can be replaced while the Calculator is in use; each calculation
+// uses a single store from start to finish.
+type Calculator struct {
+	sets     atomic.Pointer[formulaSets]
+	mu       sync.Mutex // serializes writers of sets and retained
+	retained int
+	models   models
+	options  options
+}
 
-		formula := Formula{}
-
-		// Parse boolean parameters
-		formula.UsingOwnEggs = parseBool(record[colIndex["param_using_own_eggs"]])
-		
-		attemptedIVFStr := record[colIndex["param_attempted_ivf_previously"]]
-		if attemptedIVFStr == "N/A" || attemptedIVFStr == "" {
-			formula.AttemptedIVFPreviously = nil
-		} else {
-			val := parseBool(attemptedIVFStr)
-			formula.AttemptedIVFPreviously = &val
-		}
-		
-		formula.IsReasonKnown = parseBool(record[colIndex["param_is_reason_for_infertility_known"]])
-		formula.CDCFormula = record[colIndex["cdc_formula"]]
-
-		// Parse numeric coefficients
-		formula.Intercept = parseFloat(record[colIndex["formula_intercept"]])
-		formula.AgeLinearCoeff = parseFloat(record[colIndex["formula_age_linear_coefficient"]])
-		formula.AgePowerCoeff = parseFloat(record[colIndex["formula_age_power_coefficient"]])
-		formula.AgePowerFactor = parseFloat(record[colIndex["formula_age_power_factor"]])
-		formula.BMILinearCoeff = parseFloat(record[colIndex["formula_bmi_linear_coefficient"]])
-		formula.BMIPowerCoeff = parseFloat(record[colIndex["formula_bmi_power_coefficient"]])
-		formula.BMIPowerFactor = parseFloat(record[colIndex["formula_bmi_power_factor"]])
-		formula.TubalFactorTrue = parseFloat(record[colIndex["formula_tubal_factor_true_value"]])
-		formula.TubalFactorFalse = parseFloat(record[colIndex["formula_tubal_factor_false_value"]])
-		formula.MaleFactorInfertilityTrue = parseFloat(record[colIndex["formula_male_factor_infertility_true_value"]])
-		formula.MaleFactorInfertilityFalse = parseFloat(record[colIndex["formula_male_factor_infertility_false_value"]])
-		formula.EndometriosisTrue = parseFloat(record[colIndex["formula_endometriosis_true_value"]])
-		formula.EndometriosisFalse = parseFloat(record[colIndex["formula_endometriosis_false_value"]])
-		formula.OvulatoryDisorderTrue = parseFloat(record[colIndex["formula_ovulatory_disorder_true_value"]])
-		formula.OvulatoryDisorderFalse = parseFloat(record[colIndex["formula_ovulatory_disorder_false_value"]])
-		formula.DiminishedOvarianReserveTrue = parseFloat(record[colIndex["formula_diminished_ovarian_reserve_true_value"]])
-		formula.DiminishedOvarianReserveFalse = parseFloat(record[colIndex["formula_diminished_ovarian_reserve_false_value"]])
-		formula.UterineFactorTrue = parseFloat(record[colIndex["formula_uterine_factor_true_value"]])
-		formula.UterineFactorFalse = parseFloat(record[colIndex["formula_uterine_factor_false_value"]])
-		formula.OtherReasonTrue = parseFloat(record[colIndex["formula_other_reason_true_value"]])
-		formula.OtherReasonFalse = parseFloat(record[colIndex["formula_other_reason_false_value"]])
-		formula.UnexplainedInfertilityTrue = parseFloat(record[colIndex["formula_unexplained_infertility_true_value"]])
-		formula.UnexplainedInfertilityFalse = parseFloat(record[colIndex["formula_unexplained_infertility_false_value"]])
-		formula.PriorPregnancies0 = parseFloat(record[colIndex["formula_prior_pregnancies_0_value"]])
-		formula.PriorPregnancies1 = parseFloat(record[colIndex["formula_prior_pregnancies_1_value"]])
-		formula.PriorPregnancies2Plus = parseFloat(record[colIndex["formula_prior_pregnancies_2+_value"]])
-		formula.PriorLiveBirths0 = parseFloat(record[colIndex["formula_prior_live_births_0_value"]])
-		formula.PriorLiveBirths1 = parseFloat(record[colIndex["formula_prior_live_births_1_value"]])
-		formula.PriorLiveBirths2Plus = parseFloat(record[colIndex["formula_prior_live_births_2+_value"]])
-
-		formulas = append(formulas, formula)
+// DefaultRetainedVersions is how many stores replaced by SetStore stay
+// selectable unless SetRetainedVersions sets another limit
+const DefaultRetainedVersions = 5
+
+// New creates a Calculator that uses latest by default. Archived stores stay
+// selectable through CalculateRequest.ModelVersion.
+func New(latest *FormulaStore, archived ...*FormulaStore) *Calculator {
+	c := &Calculator{retained: DefaultRetainedVersions}
+	sets := &formulaSets{}
+	for _, store := range archived {
+		sets = sets.with(store, false)
 	}
-
-	return nil
+	c.sets.Store(sets.with(latest, true))
+	return c
 }
 
-// Helper functions
-func parseBool(s string) bool {
-	return strings.ToUpper(strings.TrimSpace(s)) == "TRUE"
+// Store returns the latest formula store
+func (c *Calculator) Store() *FormulaStore {
+	return c.sets.Load().latest
 }
 
-func parseFloat(s string) float64 {
-	val, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+// SetStore atomically replaces the latest formula store. The previous store
+// remains selectable by its version so earlier results can be reproduced,
+// until more stores than the retained limit have been replaced after it.
+// Calculations already in progress finish with the store they started with.
+// A store with the version of a selectable store but other formulas is
+// rejected with ErrVersionConflict.
+func (c *Calculator) SetStore(store *FormulaStore) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sets, err := c.sets.Load().replace(store, c.retained)
 	if err != nil {
-		return 0.0
+		return err
 	}
-	return val
+	c.sets.Store(sets)
+	return nil
 }
 
-// getCSVPath returns the path to the CSV file
-func getCSVPath() string {
-	// Get the directory of the caller (calculator.go)
-	_, filename, _, ok := runtime.Caller(1)
-	if !ok {
-		// Fallback: try relative path
-		_, filename, _, ok = runtime.Caller(0)
-		if !ok {
-			return "ivf_success_formulas.csv"
-		}
+// SetRetainedVersions sets how many stores replaced by SetStore stay
+// selectable by version. Stores the Calculator was created with as archived
+// are not counted and always stay selectable.
+func (c *Calculator) SetRetainedVersions(n int) error {
+	if n < 0 {
+		return fmt.Errorf("%w: retained versions must not be negative, got %d", ErrInvalidOption, n)
 	}
-	return filepath.Join(filepath.Dir(filename), "ivf_success_formulas.csv")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retained = n
+	// Replacing the latest store with itself cannot conflict
+	sets, _ := c.sets.Load().replace(c.sets.Load().latest, n)
+	c.sets.Store(sets)
+	return nil
 }
 
-// findMatchingFormula selects the appropriate formula based on patient parameters
-func findMatchingFormula(req CalculateRequest) *Formula {
-	usingOwnEggs := req.EggSource == "own"
-	attemptedIVFPreviously := req.PriorIvfCycles == "yes"
-	isReasonKnown := !contains(req.Reasons, "unknown")
-
-	for i := range formulas {
-		f := &formulas[i]
-		
-		// Match using own eggs
-		if f.UsingOwnEggs != usingOwnEggs {
-			continue
-		}
-		
-		// Match attempted IVF previously (for own eggs only)
-		if f.UsingOwnEggs {
-			if f.AttemptedIVFPreviously == nil {
-				continue
-			}
-			if *f.AttemptedIVFPreviously != attemptedIVFPreviously {
-				continue
-			}
-		} else {
-			// For donor eggs, attemptedIVFPreviously should be nil (N/A)
-			if f.AttemptedIVFPreviously != nil {
-				continue
-			}
-		}
-		
-		// Match reason known status
-		if f.IsReasonKnown != isReasonKnown {
-			continue
-		}
-		
-		return f
-	}
-	
-	return nil
+// Versions lists every formula store the Calculator can select, oldest first
+func (c *Calculator) Versions() []VersionInfo {
+	return c.sets.Load().info()
 }
 
-// calculateBMI computes BMI from weight in pounds and height in inches
-func calculateBMI(weightLbs, heightFt int, heightIn int) float64 {
-	return float64(weightLbs) / math.Pow(float64(heightFt * 12 + heightIn), 2.0) * 703
+// MaxRetrievals returns the highest number of intended retrievals any
+// selectable formula store can estimate. Calculate still rejects requests for
+// more retrievals than the matching formula estimates.
+func (c *Calculator) MaxRetrievals() int {
+	return c.sets.Load().maxRetrievals()
 }
 
-// getPriorPregnanciesValue returns the coefficient for prior pregnancies
-func (f *Formula) getPriorPregnanciesValue(count int) float64 {
-	if count == 0 {
-		return f.PriorPregnancies0
-	} else if count == 1 {
-		return f.PriorPregnancies1
+// Calculate estimates the chance of success with the model the request
+// selects, the CDC formulas by default. The response always carries the
+// breakdown when the model provides one; callers that do not want to expose it
+// can clear it.
+func (c *Calculator) Calculate(ctx context.Context, req CalculateRequest) (CalculateResponse, error) {
+	model, err := c.model(req)
+	if err != nil {
+		return CalculateResponse{}, err
 	}
-	return f.PriorPregnancies2Plus
+	return c.predict(ctx, model, req)
 }
 
-// getPriorLiveBirthsValue returns the coefficient for prior live births
-func (f *Formula) getPriorLiveBirthsValue(count int) float64 {
-	if count == 0 {
-		return f.PriorLiveBirths0
-	} else if count == 1 {
-		return f.PriorLiveBirths1
+// Explain evaluates the matching CDC formula for the request and returns every
+// additive term of the logit alongside the intermediate values
+func (c *Calculator) Explain(req CalculateRequest) (*Breakdown, error) {
+	store, err := c.sets.Load().lookup(req.ModelVersion)
+	if err != nil {
+		return nil, err
 	}
-	return f.PriorLiveBirths2Plus
+	return explain(store, req)
 }
 
-// Calculate performs IVF success rate calculation using CDC formulas
-func Calculate(req CalculateRequest) CalculateResponse {
+func explain(store *FormulaStore, req CalculateRequest) (*Breakdown, error) {
 	// If no formulas loaded, fail fast
-	if len(formulas) == 0 {
-		fmt.Errorf("No formulas loaded")
+	if store.Len() == 0 {
+		return nil, ErrNoFormulas
 	}
 
-	// Find matching formula
-	formula := findMatchingFormula(req)
-	if formula == nil {
-		fmt.Errorf("No matching formula found")
+	// Find the matching formula
+	in := &patientInputs{req: req}
+	i, err := store.findDefinition(in)
+	if err != nil {
+		return nil, err
 	}
+	if i < 0 {
+		return nil, fmt.Errorf("%w: eggSource=%q priorIvfCycles=%q reasons=%v",
+			ErrNoMatchingFormula, req.EggSource, req.PriorIvfCycles, req.Reasons)
+	}
+	formula := &store.definitions[i]
 
-	// Calculate BMI
-	bmi := calculateBMI(req.WeightLbs, req.HeightFt, req.HeightIn)
-	age := float64(req.Age)
-
-	// Calculate logit using the formula
-	logit := formula.Intercept
-
-	// Age is calculated with a linear component as well as a polynomial component:
-	// formula_age_linear_coefficient x user_age + formula_age_power_coefficient x (user_age ^ formula_age_power_factor)
-	agePower := math.Pow(age, formula.AgePowerFactor)
-	logit += formula.AgeLinearCoeff*age + formula.AgePowerCoeff*agePower
-
-	// BMI is calculated with a linear component as well as a polynomial component:
-	// formula_bmi_linear_coefficient x user_bmi + formula_bmi_power_coefficient x (user_bmi ^ formula_bmi_power_factor)
-	bmiPower := math.Pow(bmi, formula.BMIPowerFactor)
-	logit += formula.BMILinearCoeff*bmi + formula.BMIPowerCoeff*bmiPower
-
-	// Infertility factor terms
-	hasTubalFactor := contains(req.Reasons, "tubal_factor")
-	logit += ternary(hasTubalFactor, formula.TubalFactorTrue, formula.TubalFactorFalse)
-
-	hasMaleFactorInfertility := contains(req.Reasons, "male_factor_infertility")
-	logit += ternary(hasMaleFactorInfertility, formula.MaleFactorInfertilityTrue, formula.MaleFactorInfertilityFalse)
-
-	hasEndometriosis := contains(req.Reasons, "endometriosis")
-	logit += ternary(hasEndometriosis, formula.EndometriosisTrue, formula.EndometriosisFalse)
+	if err := checkDomain(req); err != nil {
+		return nil, err
+	}
 
-	hasOvulatoryDisorder := contains(req.Reasons, "ovulatory_disorder")
-	logit += ternary(hasOvulatoryDisorder, formula.OvulatoryDisorderTrue, formula.OvulatoryDisorderFalse)
+	retrievals := req.retrievalCount()
+	if retrievals < 1 || retrievals > formula.MaxRetrievals() {
+		return nil, fmt.Errorf("%w: formula %s supports 1 to %d retrievals, got %d",
+			ErrUnsupportedRetrievals, formula.ID, formula.MaxRetrievals(), retrievals)
+	}
 
-	hasDiminishedOvarianReserve := contains(req.Reasons, "diminished_ovarian_reserve")
-	logit += ternary(hasDiminishedOvarianReserve, formula.DiminishedOvarianReserveTrue, formula.DiminishedOvarianReserveFalse)
+	// Calculate BMI
+	bmi, err := in.number(inputBMI)
+	if err != nil {
+		return nil, err
+	}
 
-	hasUterineFactor := contains(req.Reasons, "uterine_factor")
-	logit += ternary(hasUterineFactor, formula.UterineFactorTrue, formula.UterineFactorFalse)
+	// Evaluate the terms of the formula, e.g. age with a linear as well as a
+	// polynomial component: age_linear x age + age_power x (age ^ exponent)
+	terms, err := formula.terms(in)
+	if err != nil {
+		return nil, err
+	}
 
-	hasOther := contains(req.Reasons, "other")
-	logit += ternary(hasOther, formula.OtherReasonTrue, formula.OtherReasonFalse)
+	logit := 0.0
+	for _, term := range terms {
+		logit += term.Contribution
+	}
 
-	hasUnexplained := contains(req.Reasons, "unexplained")
-	logit += ternary(hasUnexplained, formula.UnexplainedInfertilityTrue, formula.UnexplainedInfertilityFalse)
+	// Convert logit to probability
+	probability := logistic(logit)
+	if math.IsNaN(probability) || math.IsInf(logit, 0) {
+		return nil, fmt.Errorf("%w: logit %v is not finite", ErrOutOfDomain, logit)
+	}
 
-	// Prior pregnancies and births
-	logit += formula.getPriorPregnanciesValue(req.PriorPregnancies)
-	logit += formula.getPriorLiveBirthsValue(req.PriorBirths)
+	return &Breakdown{
+		FormulaID:          formula.ID,
+		BMI:                bmi,
+		Terms:              terms,
+		Logit:              logit,
+		Probability:        probability,
+		LogitStandardError: formula.logitStandardError(terms),
+		maxRetrievals:      formula.MaxRetrievals(),
+	}, nil
+}
 
-	// Convert logit to probability
-	probability :=  math.Exp(logit) / (1.0 + math.Exp(logit))
+// toPercent converts a probability to a percentage rounded up to 2 decimal places
+func toPercent(probability float64) float64 {
+	return math.Ceil(probability*10000.0) / 100.0
+}
 
-	// Convert to percentage rounded to 2 decimal places
-	chancePercent := math.Ceil(probability * 10000.0) / 100.0
+// checkDomain rejects inputs the formula cannot be evaluated for. Body
+// measurements are checked when computing BMI.
+func checkDomain(req CalculateRequest) error {
+	if req.Age <= 0 {
+		return fmt.Errorf("%w: age must be positive, got %d", ErrOutOfDomain, req.Age)
+	}
+	if req.PriorPregnancies < 0 || req.PriorBirths < 0 {
+		return fmt.Errorf("%w: prior pregnancies and births cannot be negative", ErrOutOfDomain)
+	}
+	return nil
+}
 
-	return CalculateResponse{
-		CumulativeChancePercent: chancePercent,
+// newTerm builds a logit term from a coefficient and the value it multiplies
+func newTerm(name string, coefficient, value float64) Term {
+	return Term{
+		Name:         name,
+		Coefficient:  coefficient,
+		Value:        value,
+		Contribution: coefficient * value,
 	}
 }
 
@@ -336,10 +329,3 @@ func contains(slice []string, item string) bool {
 	}
 	return false
 }
-
-func ternary(condition bool, trueVal, falseVal float64) float64 {
-	if condition {
-		return trueVal
-	}
-	return falseVal
-}
\ No newline at end of file
@@ -1,10 +1,30 @@
 package calculator
 
 import (
+	"context"
+	"errors"
 	"math"
+	"slices"
+	"strings"
 	"testing"
 )
 
+// testStore loads the embedded CDC formulas
+func testStore(t *testing.T) *FormulaStore {
+	t.Helper()
+	store, err := DefaultFormulaStore()
+	if err != nil {
+		t.Fatalf("Failed to load formulas: %v", err)
+	}
+	return store
+}
+
+// testCalculator returns a Calculator backed by the embedded CDC formulas
+func testCalculator(t *testing.T) *Calculator {
+	t.Helper()
+	return New(testStore(t))
+}
+
 // Helper function to calculate weight and height from BMI
 // BMI = weight(lbs)/703 * (height(in))^2
 // For BMI = 22.8 and height = 66 inches:
@@ -40,7 +60,10 @@ func TestCalculate_OwnEggs_NoPriorIVF_KnownReason_Scenario1(t *testing.T) {
 		EggSource: "own", // TRUE
 	}
 
-	result := Calculate(req)
+	result, err := testCalculator(t).Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
 
 	// Validate that we got a result
 	if result.CumulativeChancePercent != 62.21 {
@@ -74,7 +97,10 @@ func TestCalculate_OwnEggs_NoPriorIVF_UnknownReason_Scenario2(t *testing.T) {
 		EggSource: "own",                // TRUE
 	}
 
-	result := Calculate(req)
+	result, err := testCalculator(t).Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
 
 	// Validate that we got a result
 	if result.CumulativeChancePercent != 59.83 {
@@ -108,7 +134,10 @@ func TestCalculate_OwnEggs_PriorIVF_KnownReason_Scenario3(t *testing.T) {
 		EggSource: "own", // TRUE
 	}
 
-	result := Calculate(req)
+	result, err := testCalculator(t).Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
 
 	// Validate that we got a result
 	if result.CumulativeChancePercent != 40.89 {
@@ -141,7 +170,10 @@ func TestCalculate_OwnEggs_PriorIVF_UnknownReason_Scenario4(t *testing.T) {
 		EggSource: "own", // TRUE
 	}
 
-	result := Calculate(req)
+	result, err := testCalculator(t).Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
 
 	// Validate that we got a result
 	if result.CumulativeChancePercent != 53.82 {
@@ -174,7 +206,10 @@ func TestCalculate_DonorEggs_KnownReason_Scenario5(t *testing.T) {
 		EggSource: "donor", // TRUE
 	}
 
-	result := Calculate(req)
+	result, err := testCalculator(t).Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
 
 	// Validate that we got a result
 	if result.CumulativeChancePercent != 55.43 {
@@ -207,7 +242,10 @@ func TestCalculate_DonorEggs_UnknownReason_Scenario6(t *testing.T) {
 		EggSource: "donor", // TRUE
 	}
 
-	result := Calculate(req)
+	result, err := testCalculator(t).Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
 
 	// Validate that we got a result
 	if result.CumulativeChancePercent != 56.8 {
@@ -220,6 +258,7 @@ func TestCalculate_DonorEggs_UnknownReason_Scenario6(t *testing.T) {
 
 // Test that formulas are loaded correctly
 func TestFormulaLoading(t *testing.T) {
+	formulas := testStore(t).Formulas()
 	if len(formulas) == 0 {
 		t.Error("Formulas were not loaded. Expected at least one formula.")
 		return
@@ -257,7 +296,7 @@ func TestFindMatchingFormula1(t *testing.T) {
 		Reasons:        []string{"endometriosis", "ovulatory_disorder"},
 	}
 
-	formula1 := findMatchingFormula(req)
+	formula1 := testStore(t).findMatchingFormula(req)
 	if formula1 == nil {
 		t.Error("Expected to find a matching formula for scenario 1, got nil")
 	} else {
@@ -282,7 +321,7 @@ func TestFindMatchingFormula2(t *testing.T) {
 		Reasons:        []string{"unknown"},
 	}
 
-	formula2 := findMatchingFormula(req)
+	formula2 := testStore(t).findMatchingFormula(req)
 	if formula2 == nil {
 		t.Error("Expected to find a matching formula for scenario 2, got nil")
 	} else {
@@ -307,7 +346,7 @@ func TestFindMatchingFormula3(t *testing.T) {
 		Reasons:        []string{"tubal_factor", "diminished_ovarian_reserve"},
 	}
 
-	formula3 := findMatchingFormula(req)
+	formula3 := testStore(t).findMatchingFormula(req)
 	if formula3 == nil {
 		t.Error("Expected to find a matching formula for scenario 3, got nil")
 	} else {
@@ -332,7 +371,7 @@ func TestFindMatchingFormula4(t *testing.T) {
 		Reasons:        []string{"unknown"},
 	}
 
-	formula4 := findMatchingFormula(req)
+	formula4 := testStore(t).findMatchingFormula(req)
 	if formula4 == nil {
 		t.Error("Expected to find a matching formula for scenario 4, got nil")
 	} else {
@@ -357,7 +396,7 @@ func TestFindMatchingFormula5(t *testing.T) {
 		Reasons:        []string{"unexplained"},
 	}
 
-	formula5 := findMatchingFormula(req)
+	formula5 := testStore(t).findMatchingFormula(req)
 	if formula5 == nil {
 		t.Error("Expected to find a matching formula for scenario 5, got nil")
 	} else {
@@ -382,7 +421,7 @@ func TestFindMatchingFormula6(t *testing.T) {
 		Reasons:        []string{"unknown"},
 	}
 
-	formula6 := findMatchingFormula(req)
+	formula6 := testStore(t).findMatchingFormula(req)
 	if formula6 == nil {
 		t.Error("Expected to find a matching formula for scenario 6, got nil")
 	} else {
@@ -397,4 +436,381 @@ func TestFindMatchingFormula6(t *testing.T) {
 		}
 		t.Logf("Scenario 6 matched formula: %s", formula6.CDCFormula)
 	}
-}
\ No newline at end of file
+}
+
+func TestExplain_TermsSumToLogit(t *testing.T) {
+	weightLbs, heightFt, heightIn := getWeightHeightForBMI(22.8)
+
+	req := CalculateRequest{
+		Age:              32,
+		WeightLbs:        weightLbs,
+		HeightFt:         heightFt,
+		HeightIn:         heightIn,
+		PriorIvfCycles:   "no",
+		PriorPregnancies: 1,
+		PriorBirths:      1,
+		Reasons:          []string{"endometriosis", "ovulatory_disorder"},
+		EggSource:        "own",
+	}
+
+	breakdown, err := testCalculator(t).Explain(req)
+	if err != nil {
+		t.Fatalf("Explain returned error: %v", err)
+	}
+
+	if breakdown.FormulaID != "1-3" {
+		t.Errorf("Expected formula 1-3, got %s", breakdown.FormulaID)
+	}
+
+	// intercept, 2 age terms, 2 BMI terms, 8 reasons, pregnancies and births
+	if len(breakdown.Terms) != 15 {
+		t.Errorf("Expected 15 terms, got %d", len(breakdown.Terms))
+	}
+
+	sum := 0.0
+	names := make(map[string]bool)
+	for _, term := range breakdown.Terms {
+		sum += term.Contribution
+		names[term.Name] = true
+	}
+	if sum != breakdown.Logit {
+		t.Errorf("Expected terms to sum to logit %f, got %f", breakdown.Logit, sum)
+	}
+
+	for _, name := range []string{"intercept", "endometriosis_true", "tubal_factor_false", "prior_pregnancies_1", "prior_live_births_1"} {
+		if !names[name] {
+			t.Errorf("Expected term %s in breakdown", name)
+		}
+	}
+
+	result, err := testCalculator(t).Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if got := math.Ceil(breakdown.Probability*10000.0) / 100.0; got != result.CumulativeChancePercent {
+		t.Errorf("Expected breakdown probability to match Calculate, got %f", got)
+	}
+}
+
+func TestCalculate_Errors(t *testing.T) {
+	valid := CalculateRequest{
+		Age:              32,
+		WeightLbs:        141,
+		HeightFt:         5,
+		HeightIn:         6,
+		PriorIvfCycles:   "no",
+		PriorPregnancies: 0,
+		PriorBirths:      0,
+		Reasons:          []string{"unknown"},
+		EggSource:        "own",
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(req *CalculateRequest)
+		wantErr error
+	}{
+		{
+			name:    "unknown egg source",
+			mutate:  func(req *CalculateRequest) { req.EggSource = "other" },
+			wantErr: ErrNoMatchingFormula,
+		},
+		{
+			name:    "zero height",
+			mutate:  func(req *CalculateRequest) { req.HeightFt, req.HeightIn = 0, 0 },
+			wantErr: ErrOutOfDomain,
+		},
+		{
+			name:    "zero age",
+			mutate:  func(req *CalculateRequest) { req.Age = 0 },
+			wantErr: ErrOutOfDomain,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.mutate(&req)
+			if _, err := testCalculator(t).Calculate(context.Background(), req); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCalculate_NoFormulas(t *testing.T) {
+	calc := New(NewFormulaStore(nil))
+	if _, err := calc.Calculate(context.Background(), CalculateRequest{}); !errors.Is(err, ErrNoFormulas) {
+		t.Errorf("Expected ErrNoFormulas, got %v", err)
+	}
+}
+
+func TestLoadFormulas_Reader(t *testing.T) {
+	file, err := defaultFormulaFS.Open(defaultFormulaFile)
+	if err != nil {
+		t.Fatalf("Failed to open embedded CSV: %v", err)
+	}
+	defer file.Close()
+
+	store, err := LoadFormulas(file)
+	if err != nil {
+		t.Fatalf("LoadFormulas returned error: %v", err)
+	}
+	if store.Len() != 6 {
+		t.Errorf("Expected 6 formulas, got %d", store.Len())
+	}
+}
+
+func TestLoadFormulasFile_Missing(t *testing.T) {
+	if _, err := LoadFormulasFile("does_not_exist.csv"); err == nil {
+		t.Error("Expected error loading a missing file")
+	}
+}
+
+func TestCalculator_SetStore(t *testing.T) {
+	store := testStore(t)
+	if len(store.Checksum()) != 64 {
+		t.Fatalf("Expected a SHA-256 checksum, got %q", store.Checksum())
+	}
+
+	calc := New(NewFormulaStore(nil))
+	if _, err := calc.Calculate(context.Background(), CalculateRequest{}); !errors.Is(err, ErrNoFormulas) {
+		t.Fatalf("Expected ErrNoFormulas before swapping stores, got %v", err)
+	}
+
+	calc.SetStore(store)
+	result, err := calc.Calculate(context.Background(), CalculateRequest{
+		Age:       32,
+		WeightLbs: 141,
+		HeightFt:  5,
+		HeightIn:  6,
+		Reasons:   []string{"unknown"},
+		EggSource: "donor",
+	})
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if result.FormulaChecksum != store.Checksum() {
+		t.Errorf("Expected checksum %s, got %s", store.Checksum(), result.FormulaChecksum)
+	}
+}
+
+func TestCalculate_ModelVersion(t *testing.T) {
+	current := testStore(t)
+	archived := current.WithVersion("2023-10")
+
+	calc := New(current, archived)
+	req := CalculateRequest{
+		Age:       32,
+		WeightLbs: 141,
+		HeightFt:  5,
+		HeightIn:  6,
+		Reasons:   []string{"unknown"},
+		EggSource: "donor",
+	}
+
+	result, err := calc.Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if result.ModelVersion != current.Version() {
+		t.Errorf("Expected latest version %s by default, got %s", current.Version(), result.ModelVersion)
+	}
+
+	req.ModelVersion = "2023-10"
+	result, err = calc.Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if result.ModelVersion != "2023-10" {
+		t.Errorf("Expected version 2023-10, got %s", result.ModelVersion)
+	}
+
+	req.ModelVersion = "1999-01"
+	if _, err := calc.Calculate(context.Background(), req); !errors.Is(err, ErrUnknownModelVersion) {
+		t.Errorf("Expected ErrUnknownModelVersion, got %v", err)
+	}
+}
+
+func TestCalculator_SetStoreKeepsPreviousVersion(t *testing.T) {
+	previous := testStore(t).WithVersion("previous")
+	calc := New(previous)
+	calc.SetStore(testStore(t).WithVersion("next"))
+
+	versions := calc.Versions()
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %v", versions)
+	}
+	if versions[0].Version != "previous" || versions[0].Latest {
+		t.Errorf("Expected previous to be archived, got %+v", versions[0])
+	}
+	if versions[1].Version != "next" || !versions[1].Latest {
+		t.Errorf("Expected next to be latest, got %+v", versions[1])
+	}
+}
+
+func TestCalculator_SetStoreVersionConflict(t *testing.T) {
+	lines := readDefaultCSV(t)
+	lines[1] = replaceCell(t, lines[0], lines[1], "formula_intercept", "0.5")
+	changed, err := LoadFormulas(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("LoadFormulas returned error: %v", err)
+	}
+
+	original := testStore(t).WithVersion("v1")
+	calc := New(original)
+	if err := calc.SetStore(testStore(t).WithVersion("v1")); err != nil {
+		t.Errorf("Expected the same formulas under the same version to be accepted, got %v", err)
+	}
+	if err := calc.SetStore(testStore(t).WithVersion("v2")); err != nil {
+		t.Fatalf("SetStore returned error: %v", err)
+	}
+
+	// v1 is still selectable, so other formulas cannot take its name
+	if err := calc.SetStore(changed.WithVersion("v1")); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+	if calc.Store().Version() != "v2" {
+		t.Errorf("Expected v2 to stay the latest, got %s", calc.Store().Version())
+	}
+	result, err := calc.Calculate(context.Background(), CalculateRequest{
+		Age: 32, BMI: 22.8, EggSource: "donor", Reasons: []string{"unknown"}, ModelVersion: "v1",
+	})
+	if err != nil || result.FormulaChecksum != original.Checksum() {
+		t.Errorf("Expected v1 to keep checksum %s, got %+v, %v", original.Checksum(), result, err)
+	}
+}
+
+func TestCalculator_SetStoreEvictsOldVersions(t *testing.T) {
+	calc := New(testStore(t).WithVersion("v1"), testStore(t).WithVersion("archived"))
+	if err := calc.SetRetainedVersions(2); err != nil {
+		t.Fatalf("SetRetainedVersions returned error: %v", err)
+	}
+	for _, version := range []string{"v2", "v3", "v4"} {
+		calc.SetStore(testStore(t).WithVersion(version))
+	}
+
+	var versions []string
+	for _, version := range calc.Versions() {
+		versions = append(versions, version.Version)
+	}
+	// v1 is the oldest replaced store beyond the limit; archived stores are kept
+	if want := []string{"archived", "v2", "v3", "v4"}; !slices.Equal(versions, want) {
+		t.Errorf("Expected versions %v, got %v", want, versions)
+	}
+	if _, err := calc.Calculate(context.Background(), CalculateRequest{
+		Age: 32, BMI: 22.8, EggSource: "donor", Reasons: []string{"unknown"}, ModelVersion: "v1",
+	}); !errors.Is(err, ErrUnknownModelVersion) {
+		t.Errorf("Expected ErrUnknownModelVersion for an evicted version, got %v", err)
+	}
+
+	if err := calc.SetRetainedVersions(0); err != nil {
+		t.Fatalf("SetRetainedVersions returned error: %v", err)
+	}
+	if versions := calc.Versions(); len(versions) != 2 || !versions[1].Latest {
+		t.Errorf("Expected only the archived and latest versions, got %+v", versions)
+	}
+	if err := calc.SetRetainedVersions(-1); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestCalculate_Retrievals(t *testing.T) {
+	lines := readDefaultCSV(t)
+	lines[0] += ",formula_retrievals_1_value,formula_retrievals_2_value,formula_retrievals_3_value"
+	for i := 1; i < len(lines); i++ {
+		lines[i] += ",0,0.5,0.8"
+	}
+	store, err := LoadFormulas(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("LoadFormulas returned error: %v", err)
+	}
+
+	req := CalculateRequest{
+		Age:              32,
+		WeightLbs:        141,
+		HeightFt:         5,
+		HeightIn:         6,
+		PriorIvfCycles:   "no",
+		PriorPregnancies: 1,
+		PriorBirths:      1,
+		Reasons:          []string{"endometriosis", "ovulatory_disorder"},
+		EggSource:        "own",
+		Retrievals:       2,
+	}
+
+	result, err := New(store).Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if len(result.ChancesByRetrieval) != 3 {
+		t.Fatalf("Expected chances for 3 retrievals, got %v", result.ChancesByRetrieval)
+	}
+	if result.ChancesByRetrieval[0].CumulativeChancePercent != 62.21 {
+		t.Errorf("Expected 62.21 for one retrieval, got %f", result.ChancesByRetrieval[0].CumulativeChancePercent)
+	}
+	if result.CumulativeChancePercent != result.ChancesByRetrieval[1].CumulativeChancePercent {
+		t.Errorf("Expected result for 2 retrievals, got %f", result.CumulativeChancePercent)
+	}
+	for i := 1; i < len(result.ChancesByRetrieval); i++ {
+		if result.ChancesByRetrieval[i].CumulativeChancePercent <= result.ChancesByRetrieval[i-1].CumulativeChancePercent {
+			t.Errorf("Expected chances to increase with retrievals, got %v", result.ChancesByRetrieval)
+		}
+	}
+
+	// The CDC CSV has no retrieval columns, so it only estimates one retrieval
+	if _, err := testCalculator(t).Calculate(context.Background(), req); !errors.Is(err, ErrUnsupportedRetrievals) {
+		t.Errorf("Expected ErrUnsupportedRetrievals, got %v", err)
+	}
+
+	// Validation accepts the most retrievals of any selectable version
+	if got := testCalculator(t).MaxRetrievals(); got != 1 {
+		t.Errorf("Expected the CDC CSV to estimate 1 retrieval, got %d", got)
+	}
+	if got := New(testStore(t), store.WithVersion("retrievals")).MaxRetrievals(); got != 3 {
+		t.Errorf("Expected 3 retrievals with an archived version estimating 3, got %d", got)
+	}
+}
+
+func TestCalculate_MetricAndBMIUnits(t *testing.T) {
+	base := CalculateRequest{
+		Age:              32,
+		PriorIvfCycles:   "no",
+		PriorPregnancies: 1,
+		PriorBirths:      1,
+		Reasons:          []string{"endometriosis", "ovulatory_disorder"},
+		EggSource:        "own",
+	}
+
+	imperial := base
+	imperial.WeightLbs, imperial.HeightFt, imperial.HeightIn = 141, 5, 6
+
+	// 141 lbs and 5'6" converted exactly
+	metric := base
+	metric.WeightKg, metric.HeightCm = 141*0.45359237, 66*2.54
+
+	direct := base
+	direct.BMI = calculateBMI(141, 5, 6)
+
+	want, err := testCalculator(t).Explain(imperial)
+	if err != nil {
+		t.Fatalf("Explain returned error: %v", err)
+	}
+
+	for name, req := range map[string]CalculateRequest{"metric": metric, "bmi": direct} {
+		got, err := testCalculator(t).Explain(req)
+		if err != nil {
+			t.Fatalf("%s: Explain returned error: %v", name, err)
+		}
+		// The imperial formula uses the rounded 703 factor, so allow a small difference
+		if math.Abs(got.BMI-want.BMI) > 0.01 {
+			t.Errorf("%s: expected BMI %f, got %f", name, want.BMI, got.BMI)
+		}
+	}
+
+	mixed := imperial
+	mixed.BMI = 22.8
+	if _, err := testCalculator(t).Calculate(context.Background(), mixed); !errors.Is(err, ErrOutOfDomain) {
+		t.Errorf("Expected ErrOutOfDomain for mixed units, got %v", err)
+	}
+}
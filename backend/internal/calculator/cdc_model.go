@@ -0,0 +1,63 @@
+package calculator
+
+import "context"
+
+// CDCModelName is the name of the model that evaluates the CDC formulas
+const CDCModelName = "cdc"
+
+// CDCModel predicts with the CDC logit formulas of a FormulaStore
+type CDCModel struct {
+	store *FormulaStore
+}
+
+// NewCDCModel creates a model that evaluates the formulas of store
+func NewCDCModel(store *FormulaStore) *CDCModel {
+	return &CDCModel{store: store}
+}
+
+// Info describes the model, versioned by its formulas
+func (m *CDCModel) Info() ModelInfo {
+	return ModelInfo{
+		Name:        CDCModelName,
+		Version:     m.store.Version(),
+		Checksum:    m.store.Checksum(),
+		Description: "CDC IVF Success Estimator logit formulas",
+		Covariates:  []string{"age", "bmi", "eggSource", "priorIvfCycles", "reasons", "priorPregnancies", "priorBirths", "retrievals"},
+	}
+}
+
+// Predict evaluates the formula matching the patient, and the chance after
+// every number of retrievals the formula supports
+func (m *CDCModel) Predict(ctx context.Context, patient Patient) (Prediction, error) {
+	if err := ctx.Err(); err != nil {
+		return Prediction{}, err
+	}
+
+	req := patient.request()
+	breakdown, err := explain(m.store, req)
+	if err != nil {
+		return Prediction{}, err
+	}
+
+	byRetrieval := make([]float64, breakdown.maxRetrievals)
+	for retrievals := 1; retrievals <= breakdown.maxRetrievals; retrievals++ {
+		chance := breakdown
+		if retrievals != patient.Retrievals {
+			other := req
+			other.Retrievals = retrievals
+			if chance, err = explain(m.store, other); err != nil {
+				return Prediction{}, err
+			}
+		}
+		byRetrieval[retrievals-1] = chance.Probability
+	}
+
+	return Prediction{
+		Probability: breakdown.Probability,
+		ByRetrieval: byRetrieval,
+		FormulaID:   breakdown.FormulaID,
+		Breakdown:   breakdown,
+		// Intervals are only estimated for the intended number of retrievals
+		LogitStandardError: breakdown.LogitStandardError,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package calculator
+
+import "context"
+
+// CompareOption is the result for one egg source in a comparison. Own eggs
+// also carry the prior IVF answer the option was calculated with.
+type CompareOption struct {
+	EggSource      string `json:"eggSource"`
+	PriorIvfCycles string `json:"priorIvfCycles,omitempty"`
+	CalculateResponse
+}
+
+// CompareOptions returns the requests to compare for a patient: own eggs with
+// the request's prior IVF answer, or with both answers when it is not given,
+// then donor eggs. The request's own egg source is ignored.
+func CompareOptions(req CalculateRequest) []CalculateRequest {
+	priorIvf := []string{req.PriorIvfCycles}
+	if req.PriorIvfCycles == "" {
+		priorIvf = []string{"no", "yes"}
+	}
+
+	var options []CalculateRequest
+	for _, answer := range priorIvf {
+		own := req
+		own.EggSource = "own"
+		own.PriorIvfCycles = answer
+		options = append(options, own)
+	}
+
+	donor := req
+	donor.EggSource = "donor"
+	donor.PriorIvfCycles = ""
+	return append(options, donor)
+}
+
+// Compare calculates every option of CompareOptions with the same model and
+// version, so the patient can see own and donor eggs side by side. It fails
+// if any option cannot be calculated.
+func (c *Calculator) Compare(ctx context.Context, req CalculateRequest) ([]CompareOption, error) {
+	model, err := c.model(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var options []CompareOption
+	for _, option := range CompareOptions(req) {
+		result, err := c.predict(ctx, model, option)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, CompareOption{
+			EggSource:         option.EggSource,
+			PriorIvfCycles:    option.PriorIvfCycles,
+			CalculateResponse: result,
+		})
+	}
+	return options, nil
+}
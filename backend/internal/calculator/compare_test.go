@@ -0,0 +1,86 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	base := CalculateRequest{
+		Age:              32,
+		BMI:              22.8,
+		PriorPregnancies: 1,
+		PriorBirths:      1,
+		Reasons:          []string{"endometriosis", "ovulatory_disorder"},
+	}
+
+	tests := []struct {
+		name           string
+		eggSource      string
+		priorIvfCycles string
+		wantOptions    []string // egg source and prior IVF answer of each option
+		wantFormulas   []string
+	}{
+		{
+			name:           "known prior IVF",
+			eggSource:      "own",
+			priorIvfCycles: "no",
+			wantOptions:    []string{"own/no", "donor/"},
+			wantFormulas:   []string{"1-3", "11-13"},
+		},
+		{
+			name:           "unknown prior IVF compares both own egg formulas",
+			eggSource:      "donor",
+			priorIvfCycles: "",
+			wantOptions:    []string{"own/no", "own/yes", "donor/"},
+			wantFormulas:   []string{"1-3", "7-8", "11-13"},
+		},
+	}
+
+	calc := testCalculator(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := base
+			req.EggSource = tt.eggSource
+			req.PriorIvfCycles = tt.priorIvfCycles
+
+			options, err := calc.Compare(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Compare returned error: %v", err)
+			}
+
+			var gotOptions, gotFormulas []string
+			for _, option := range options {
+				gotOptions = append(gotOptions, option.EggSource+"/"+option.PriorIvfCycles)
+				gotFormulas = append(gotFormulas, option.FormulaID)
+
+				// Each option must match calculating it on its own
+				single := req
+				single.EggSource = option.EggSource
+				single.PriorIvfCycles = option.PriorIvfCycles
+				want, err := calc.Calculate(context.Background(), single)
+				if err != nil {
+					t.Fatalf("Calculate returned error: %v", err)
+				}
+				if !reflect.DeepEqual(option.CalculateResponse, want) {
+					t.Errorf("Option %s/%s = %+v, want %+v", option.EggSource, option.PriorIvfCycles, option.CalculateResponse, want)
+				}
+			}
+			if !reflect.DeepEqual(gotOptions, tt.wantOptions) {
+				t.Errorf("Options = %v, want %v", gotOptions, tt.wantOptions)
+			}
+			if !reflect.DeepEqual(gotFormulas, tt.wantFormulas) {
+				t.Errorf("Formulas = %v, want %v", gotFormulas, tt.wantFormulas)
+			}
+		})
+	}
+}
+
+func TestCompare_UnknownModelVersion(t *testing.T) {
+	_, err := testCalculator(t).Compare(context.Background(), CalculateRequest{Age: 32, BMI: 22.8, Reasons: []string{"other"}, ModelVersion: "1999-01"})
+	if !errors.Is(err, ErrUnknownModelVersion) {
+		t.Errorf("Expected ErrUnknownModelVersion, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Dimensions a sensitivity curve can vary
+const (
+	CurveAge    = "age"
+	CurveBMI    = "bmi"
+	CurveWeight = "weight" // in the base request's unit system (lbs or kg)
+)
+
+// MaxCurvePoints limits how many points a single curve can compute
+const MaxCurvePoints = 200
+
+// CurveRequest represents the request body for the curve endpoint: a base
+// patient plus one dimension varied from From to To in increments of Step
+type CurveRequest struct {
+	Base      CalculateRequest `json:"base" binding:"required"`
+	Dimension string           `json:"dimension" binding:"required"`
+	From      float64          `json:"from"`
+	To        float64          `json:"to"`
+	Step      float64          `json:"step" binding:"required"`
+}
+
+// CurvePoint is the result for one value of the varied dimension
+type CurvePoint struct {
+	Value                   float64 `json:"value"`
+	CumulativeChancePercent float64 `json:"cumulativeChancePercent"`
+	Probability             float64 `json:"probability"`
+}
+
+// CurveResponse represents the response from the curve endpoint
+type CurveResponse struct {
+	Dimension       string       `json:"dimension"`
+	Points          []CurvePoint `json:"points"`
+	Rounding        string       `json:"rounding"`
+	Model           string       `json:"model,omitempty"`
+	ModelVersion    string       `json:"modelVersion,omitempty"`
+	FormulaChecksum string       `json:"formulaChecksum,omitempty"`
+}
+
+// Values returns the values of the varied dimension from From to To inclusive
+func (r CurveRequest) Values() ([]float64, error) {
+	if r.Step <= 0 || math.IsNaN(r.Step) {
+		return nil, fmt.Errorf("%w: step must be positive", ErrOutOfDomain)
+	}
+	if r.To < r.From {
+		return nil, fmt.Errorf("%w: to must not be less than from", ErrOutOfDomain)
+	}
+
+	// Checked as a float, since a tiny step or an infinite range would
+	// overflow the conversion to int
+	count := math.Floor((r.To-r.From)/r.Step+1e-9) + 1
+	if !(count <= MaxCurvePoints) {
+		return nil, fmt.Errorf("%w: curve has %v points, at most %d are allowed", ErrOutOfDomain, count, MaxCurvePoints)
+	}
+
+	values := make([]float64, int(count))
+	for i := range values {
+		// Computed from the start each time so steps do not accumulate rounding error
+		values[i] = math.Round((r.From+float64(i)*r.Step)*1e6) / 1e6
+	}
+	return values, nil
+}
+
+// Requests expands the curve into one CalculateRequest per point
+func (r CurveRequest) Requests() ([]CalculateRequest, error) {
+	values, err := r.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]CalculateRequest, len(values))
+	for i, value := range values {
+		if reqs[i], err = r.at(value); err != nil {
+			return nil, err
+		}
+	}
+	return reqs, nil
+}
+
+// at returns the base request with the varied dimension set to value
+func (r CurveRequest) at(value float64) (CalculateRequest, error) {
+	req := r.Base
+	req.Reasons = append([]string(nil), r.Base.Reasons...)
+
+	switch r.Dimension {
+	case CurveAge:
+		if value != math.Trunc(value) {
+			return req, fmt.Errorf("%w: age must be a whole number, got %v", ErrOutOfDomain, value)
+		}
+		req.Age = int(value)
+	case CurveBMI:
+		req.WeightLbs, req.HeightFt, req.HeightIn = 0, 0, 0
+		req.WeightKg, req.HeightCm = 0, 0
+		req.BMI = value
+	case CurveWeight:
+		systems := r.Base.UnitSystems()
+		switch {
+		case len(systems) != 1 || systems[0] == UnitsBMI:
+			return req, fmt.Errorf("%w: weight can only be varied for imperial or metric requests", ErrOutOfDomain)
+		case systems[0] == UnitsMetric:
+			req.WeightKg = value
+		default:
+			if value != math.Trunc(value) {
+				return req, fmt.Errorf("%w: weightLbs must be a whole number, got %v", ErrOutOfDomain, value)
+			}
+			req.WeightLbs = int(value)
+		}
+	default:
+		return req, fmt.Errorf("%w: unknown curve dimension %q", ErrOutOfDomain, r.Dimension)
+	}
+	return req, nil
+}
+
+// Curve computes the chance of success at every point of a sensitivity curve.
+// All points are computed with the same model and version.
+func (c *Calculator) Curve(ctx context.Context, req CurveRequest) (CurveResponse, error) {
+	model, err := c.model(req.Base)
+	if err != nil {
+		return CurveResponse{}, err
+	}
+	rounding := c.rounding(req.Base)
+	if err := checkRounding(rounding); err != nil {
+		return CurveResponse{}, err
+	}
+
+	values, err := req.Values()
+	if err != nil {
+		return CurveResponse{}, err
+	}
+	reqs, err := req.Requests()
+	if err != nil {
+		return CurveResponse{}, err
+	}
+
+	points := make([]CurvePoint, len(reqs))
+	for i, pointReq := range reqs {
+		prediction, err := predictPoint(ctx, model, pointReq)
+		if err != nil {
+			return CurveResponse{}, fmt.Errorf("%s %v: %w", req.Dimension, values[i], err)
+		}
+		points[i] = CurvePoint{
+			Value:                   values[i],
+			CumulativeChancePercent: roundPercent(prediction.Probability, rounding),
+			Probability:             prediction.Probability,
+		}
+	}
+
+	info := model.Info()
+	return CurveResponse{
+		Dimension:       req.Dimension,
+		Points:          points,
+		Rounding:        rounding,
+		Model:           info.Name,
+		ModelVersion:    info.Version,
+		FormulaChecksum: info.Checksum,
+	}, nil
+}
+
+func predictPoint(ctx context.Context, model Model, req CalculateRequest) (Prediction, error) {
+	patient, err := NewPatient(req)
+	if err != nil {
+		return Prediction{}, err
+	}
+	return model.Predict(ctx, patient)
+}
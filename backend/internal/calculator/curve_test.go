@@ -0,0 +1,101 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func scenario1Request() CalculateRequest {
+	return CalculateRequest{
+		Age:              32,
+		WeightLbs:        141,
+		HeightFt:         5,
+		HeightIn:         6,
+		PriorIvfCycles:   "no",
+		PriorPregnancies: 1,
+		PriorBirths:      1,
+		Reasons:          []string{"endometriosis", "ovulatory_disorder"},
+		EggSource:        "own",
+	}
+}
+
+func TestCurve_Age(t *testing.T) {
+	result, err := testCalculator(t).Curve(context.Background(), CurveRequest{
+		Base:      scenario1Request(),
+		Dimension: CurveAge,
+		From:      30,
+		To:        40,
+		Step:      2,
+	})
+	if err != nil {
+		t.Fatalf("Curve returned error: %v", err)
+	}
+
+	if len(result.Points) != 6 {
+		t.Fatalf("Expected 6 points, got %d", len(result.Points))
+	}
+	// The point at the base age matches the single calculation
+	if result.Points[1].Value != 32 || result.Points[1].CumulativeChancePercent != 62.21 {
+		t.Errorf("Expected 62.21 at age 32, got %+v", result.Points[1])
+	}
+	for i := 1; i < len(result.Points); i++ {
+		if result.Points[i].Probability >= result.Points[i-1].Probability {
+			t.Errorf("Expected chance to fall with age, got %+v then %+v", result.Points[i-1], result.Points[i])
+		}
+	}
+}
+
+func TestCurve_BMIAndWeight(t *testing.T) {
+	bmiCurve, err := testCalculator(t).Curve(context.Background(), CurveRequest{
+		Base:      scenario1Request(),
+		Dimension: CurveBMI,
+		From:      18.5,
+		To:        30,
+		Step:      0.5,
+	})
+	if err != nil {
+		t.Fatalf("Curve returned error: %v", err)
+	}
+	if len(bmiCurve.Points) != 24 || bmiCurve.Points[23].Value != 30 {
+		t.Errorf("Expected 24 points ending at 30, got %d", len(bmiCurve.Points))
+	}
+
+	weightCurve, err := testCalculator(t).Curve(context.Background(), CurveRequest{
+		Base:      scenario1Request(),
+		Dimension: CurveWeight,
+		From:      121,
+		To:        161,
+		Step:      20,
+	})
+	if err != nil {
+		t.Fatalf("Curve returned error: %v", err)
+	}
+	if weightCurve.Points[1].CumulativeChancePercent != 62.21 {
+		t.Errorf("Expected 62.21 at 141 lbs, got %+v", weightCurve.Points[1])
+	}
+
+	bmiBase := scenario1Request()
+	bmiBase.WeightLbs, bmiBase.HeightFt, bmiBase.HeightIn = 0, 0, 0
+	bmiBase.BMI = 22.8
+	_, err = testCalculator(t).Curve(context.Background(), CurveRequest{Base: bmiBase, Dimension: CurveWeight, From: 100, To: 120, Step: 10})
+	if !errors.Is(err, ErrOutOfDomain) {
+		t.Errorf("Expected ErrOutOfDomain varying weight of a BMI request, got %v", err)
+	}
+}
+
+func TestCurve_TooManyPoints(t *testing.T) {
+	// A step of 1e-300 gives more points than an int can hold
+	for _, step := range []float64{0.1, 1e-300} {
+		_, err := testCalculator(t).Curve(context.Background(), CurveRequest{
+			Base:      scenario1Request(),
+			Dimension: CurveBMI,
+			From:      10,
+			To:        90,
+			Step:      step,
+		})
+		if !errors.Is(err, ErrOutOfDomain) {
+			t.Errorf("Step %v: expected ErrOutOfDomain, got %v", step, err)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Term kinds of a TermDefinition
+const (
+	TermIntercept   = "intercept"
+	TermLinear      = "linear"
+	TermPower       = "power"
+	TermCategorical = "categorical"
+)
+
+// Inputs a formula can select on and evaluate terms of. Every infertility
+// reason is also a categorical input named reasonInputPrefix followed by the
+// reason (e.g. "reasons.tubal_factor"), whose level is "true" or "false".
+const (
+	inputAge              = "age"
+	inputBMI              = "bmi"
+	inputPriorPregnancies = "priorPregnancies"
+	inputPriorBirths      = "priorBirths"
+	inputRetrievals       = "retrievals"
+	inputEggSource        = "eggSource"
+	inputPriorIvfCycles   = "priorIvfCycles"
+	inputReasonKnown      = "reasonKnown"
+	reasonInputPrefix     = "reasons."
+)
+
+// numericInputs can be used by every kind of term; categorical terms match
+// them by whole number
+var numericInputs = []string{inputAge, inputBMI, inputPriorPregnancies, inputPriorBirths, inputRetrievals}
+
+// categoricalInputs can only be used by categorical terms and selectors
+var categoricalInputs = []string{inputEggSource, inputPriorIvfCycles, inputReasonKnown}
+
+// FormulaFile is the declarative formula format. Unlike the CSV, which has a
+// fixed column per coefficient, every formula lists its own terms, so a
+// covariate can be added without changing the calculator.
+type FormulaFile struct {
+	// Version names the formulas; when empty they are versioned by checksum
+	Version  string              `json:"version,omitempty"`
+	Formulas []FormulaDefinition `json:"formulas"`
+}
+
+// FormulaDefinition is a logit formula evaluated by a generic engine. It
+// applies to patients whose inputs have every level listed in When; the first
+// formula that applies is used. The logit is the sum of its terms in order.
+type FormulaDefinition struct {
+	ID    string            `json:"id"`
+	When  map[string]string `json:"when"`
+	Terms []TermDefinition  `json:"terms"`
+	// Covariance optionally holds the covariance of pairs of coefficients,
+	// named as in a Breakdown (e.g. "age_linear", "tubal_factor_true"), for
+	// confidence intervals. Each pair needs to be given in one order only.
+	Covariance map[string]map[string]float64 `json:"covariance,omitempty"`
+}
+
+// TermDefinition is an additive logit term. Its contribution depends on Kind:
+//
+//	intercept:   Coefficient
+//	linear:      Coefficient × Input
+//	power:       Coefficient × Input^Exponent
+//	categorical: the coefficient of the level of Input
+//
+// Categorical terms appear in a Breakdown as Name, an underscore and the level
+// (e.g. "prior_pregnancies_2+").
+type TermDefinition struct {
+	Name        string            `json:"name"`
+	Kind        string            `json:"kind"`
+	Input       string            `json:"input,omitempty"`
+	Coefficient float64           `json:"coefficient,omitempty"`
+	Exponent    float64           `json:"exponent,omitempty"`
+	Levels      []LevelDefinition `json:"levels,omitempty"`
+}
+
+// LevelDefinition is the coefficient of a categorical term for one level of
+// its input. Levels of numeric inputs are whole numbers, and "N+" matches N or
+// more when no level matches exactly.
+type LevelDefinition struct {
+	Value       string  `json:"value"`
+	Coefficient float64 `json:"coefficient"`
+}
+
+// MaxRetrievals returns the highest number of intended retrievals the formula
+// can estimate: the levels of its retrievals term, or 1 without one
+func (d *FormulaDefinition) MaxRetrievals() int {
+	for _, term := range d.Terms {
+		if term.Kind == TermCategorical && term.Input == inputRetrievals {
+			return len(term.Levels)
+		}
+	}
+	return 1
+}
+
+// applies reports whether the patient matches every condition of the formula
+func (d *FormulaDefinition) applies(in *patientInputs) (bool, error) {
+	for input, value := range d.When {
+		level, err := in.level(input, []LevelDefinition{{Value: value}})
+		if err != nil {
+			return false, err
+		}
+		if level == nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// terms evaluates every term of the formula for the patient
+func (d *FormulaDefinition) terms(in *patientInputs) ([]Term, error) {
+	terms := make([]Term, 0, len(d.Terms))
+	for _, def := range d.Terms {
+		var term Term
+		switch def.Kind {
+		case TermIntercept:
+			term = newTerm(def.Name, def.Coefficient, 1)
+		case TermLinear, TermPower:
+			value, err := in.number(def.Input)
+			if err != nil {
+				return nil, err
+			}
+			if def.Kind == TermPower {
+				value = math.Pow(value, def.Exponent)
+			}
+			term = newTerm(def.Name, def.Coefficient, value)
+		case TermCategorical:
+			level, err := in.level(def.Input, def.Levels)
+			if err != nil {
+				return nil, err
+			}
+			if level == nil {
+				return nil, fmt.Errorf("%w: formula %s has no %s coefficient for %s", ErrOutOfDomain, d.ID, def.Name, in.describe(def.Input))
+			}
+			term = newTerm(def.Name+"_"+level.Value, level.Coefficient, 1)
+		default:
+			return nil, fmt.Errorf("formula %s: unknown term kind %q", d.ID, def.Kind)
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// patientInputs resolves the inputs of formulas for a request. BMI is only
+// computed when a formula needs it, so a formula can be selected before the
+// body measurements are checked.
+type patientInputs struct {
+	req    CalculateRequest
+	bmi    float64
+	bmiErr error
+	hasBMI bool
+}
+
+// number returns the value of a numeric input
+func (in *patientInputs) number(name string) (float64, error) {
+	switch name {
+	case inputAge:
+		return float64(in.req.Age), nil
+	case inputBMI:
+		if !in.hasBMI {
+			in.bmi, in.bmiErr = bodyMassIndex(in.req)
+			in.hasBMI = true
+		}
+		return in.bmi, in.bmiErr
+	case inputPriorPregnancies:
+		return float64(in.req.PriorPregnancies), nil
+	case inputPriorBirths:
+		return float64(in.req.PriorBirths), nil
+	case inputRetrievals:
+		return float64(in.req.retrievalCount()), nil
+	}
+	return 0, fmt.Errorf("unknown numeric input %q", name)
+}
+
+// category returns the level of a categorical input, and false for inputs
+// that are not categorical
+func (in *patientInputs) category(name string) (string, bool) {
+	switch name {
+	case inputEggSource:
+		return in.req.EggSource, true
+	case inputPriorIvfCycles:
+		if in.req.PriorIvfCycles == "yes" {
+			return "yes", true
+		}
+		return "no", true
+	case inputReasonKnown:
+		return strconv.FormatBool(!contains(in.req.Reasons, "unknown")), true
+	}
+	if reason, ok := strings.CutPrefix(name, reasonInputPrefix); ok {
+		return strconv.FormatBool(contains(in.req.Reasons, reason)), true
+	}
+	return "", false
+}
+
+// level returns the level of levels that the input matches, or nil if none does
+func (in *patientInputs) level(name string, levels []LevelDefinition) (*LevelDefinition, error) {
+	if category, ok := in.category(name); ok {
+		for i := range levels {
+			if levels[i].Value == category {
+				return &levels[i], nil
+			}
+		}
+		return nil, nil
+	}
+
+	value, err := in.number(name)
+	if err != nil {
+		return nil, err
+	}
+	var match *LevelDefinition
+	atLeast := math.Inf(-1)
+	for i := range levels {
+		min, open, err := parseLevel(levels[i].Value)
+		if err != nil {
+			continue
+		}
+		if !open && value == min {
+			return &levels[i], nil
+		}
+		if open && value >= min && min > atLeast {
+			match, atLeast = &levels[i], min
+		}
+	}
+	return match, nil
+}
+
+// describe formats the value of an input for error messages
+func (in *patientInputs) describe(name string) string {
+	if category, ok := in.category(name); ok {
+		return fmt.Sprintf("%s %q", name, category)
+	}
+	value, _ := in.number(name)
+	return fmt.Sprintf("%s %g", name, value)
+}
+
+// parseLevel parses a level of a numeric input: a whole number, or a whole
+// number followed by "+" for that number or more
+func parseLevel(value string) (min float64, open bool, err error) {
+	digits, open := strings.CutSuffix(value, "+")
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid level %q, expected a whole number or N+", value)
+	}
+	return float64(n), open, nil
+}
+
+// isNumericInput reports whether name is a numeric input
+func isNumericInput(name string) bool {
+	for _, input := range numericInputs {
+		if input == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isCategoricalInput reports whether name is a categorical input
+func isCategoricalInput(name string) bool {
+	for _, input := range categoricalInputs {
+		if input == name {
+			return true
+		}
+	}
+	reason, ok := strings.CutPrefix(name, reasonInputPrefix)
+	return ok && reason != ""
+}
+
+// Definition converts a CSV formula to the declarative format. The terms are
+// listed in the order the CSV formulas have always been summed in, so both
+// evaluate to bit-identical results.
+func (f *Formula) Definition() FormulaDefinition {
+	when := map[string]string{
+		inputEggSource:   "donor",
+		inputReasonKnown: strconv.FormatBool(f.IsReasonKnown),
+	}
+	if f.UsingOwnEggs {
+		when[inputEggSource] = "own"
+	}
+	if f.AttemptedIVFPreviously != nil {
+		when[inputPriorIvfCycles] = "no"
+		if *f.AttemptedIVFPreviously {
+			when[inputPriorIvfCycles] = "yes"
+		}
+	}
+
+	terms := []TermDefinition{
+		{Name: "intercept", Kind: TermIntercept, Coefficient: f.Intercept},
+		{Name: "age_linear", Kind: TermLinear, Input: inputAge, Coefficient: f.AgeLinearCoeff},
+		{Name: "age_power", Kind: TermPower, Input: inputAge, Coefficient: f.AgePowerCoeff, Exponent: f.AgePowerFactor},
+		{Name: "bmi_linear", Kind: TermLinear, Input: inputBMI, Coefficient: f.BMILinearCoeff},
+		{Name: "bmi_power", Kind: TermPower, Input: inputBMI, Coefficient: f.BMIPowerCoeff, Exponent: f.BMIPowerFactor},
+		reasonTermDefinition("tubal_factor", f.TubalFactorTrue, f.TubalFactorFalse),
+		reasonTermDefinition("male_factor_infertility", f.MaleFactorInfertilityTrue, f.MaleFactorInfertilityFalse),
+		reasonTermDefinition("endometriosis", f.EndometriosisTrue, f.EndometriosisFalse),
+		reasonTermDefinition("ovulatory_disorder", f.OvulatoryDisorderTrue, f.OvulatoryDisorderFalse),
+		reasonTermDefinition("diminished_ovarian_reserve", f.DiminishedOvarianReserveTrue, f.DiminishedOvarianReserveFalse),
+		reasonTermDefinition("uterine_factor", f.UterineFactorTrue, f.UterineFactorFalse),
+		reasonTermDefinition("other", f.OtherReasonTrue, f.OtherReasonFalse),
+		reasonTermDefinition("unexplained", f.UnexplainedInfertilityTrue, f.UnexplainedInfertilityFalse),
+		countTermDefinition("prior_pregnancies", inputPriorPregnancies, f.PriorPregnancies0, f.PriorPregnancies1, f.PriorPregnancies2Plus),
+		countTermDefinition("prior_live_births", inputPriorBirths, f.PriorLiveBirths0, f.PriorLiveBirths1, f.PriorLiveBirths2Plus),
+	}
+
+	if len(f.RetrievalAdjustments) > 0 {
+		retrievals := TermDefinition{Name: "retrievals", Kind: TermCategorical, Input: inputRetrievals}
+		for i, adjustment := range f.RetrievalAdjustments {
+			retrievals.Levels = append(retrievals.Levels, LevelDefinition{Value: strconv.Itoa(i + 1), Coefficient: adjustment})
+		}
+		terms = append(terms, retrievals)
+	}
+
+	return FormulaDefinition{ID: f.CDCFormula, When: when, Terms: terms}
+}
+
+// reasonTermDefinition is a categorical term on whether a reason was given
+func reasonTermDefinition(reason string, trueVal, falseVal float64) TermDefinition {
+	return TermDefinition{
+		Name:  reason,
+		Kind:  TermCategorical,
+		Input: reasonInputPrefix + reason,
+		Levels: []LevelDefinition{
+			{Value: "true", Coefficient: trueVal},
+			{Value: "false", Coefficient: falseVal},
+		},
+	}
+}
+
+// countTermDefinition is a categorical term on a count of 0, 1 or 2 or more
+func countTermDefinition(name, input string, zero, one, twoPlus float64) TermDefinition {
+	return TermDefinition{
+		Name:  name,
+		Kind:  TermCategorical,
+		Input: input,
+		Levels: []LevelDefinition{
+			{Value: "0", Coefficient: zero},
+			{Value: "1", Coefficient: one},
+			{Value: "2+", Coefficient: twoPlus},
+		},
+	}
+}
@@ -0,0 +1,282 @@
+package calculator
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// csvLogit sums the logit of a CSV formula field by field, the way formulas
+// were evaluated before they were converted to definitions
+func csvLogit(f *Formula, req CalculateRequest, bmi float64) float64 {
+	age := float64(req.Age)
+	pick := func(has bool, trueVal, falseVal float64) float64 {
+		if has {
+			return trueVal
+		}
+		return falseVal
+	}
+	count := func(n int, zero, one, twoPlus float64) float64 {
+		switch n {
+		case 0:
+			return zero
+		case 1:
+			return one
+		}
+		return twoPlus
+	}
+	has := func(reason string) bool { return contains(req.Reasons, reason) }
+
+	contributions := []float64{
+		f.Intercept * 1,
+		f.AgeLinearCoeff * age,
+		f.AgePowerCoeff * math.Pow(age, f.AgePowerFactor),
+		f.BMILinearCoeff * bmi,
+		f.BMIPowerCoeff * math.Pow(bmi, f.BMIPowerFactor),
+		pick(has("tubal_factor"), f.TubalFactorTrue, f.TubalFactorFalse) * 1,
+		pick(has("male_factor_infertility"), f.MaleFactorInfertilityTrue, f.MaleFactorInfertilityFalse) * 1,
+		pick(has("endometriosis"), f.EndometriosisTrue, f.EndometriosisFalse) * 1,
+		pick(has("ovulatory_disorder"), f.OvulatoryDisorderTrue, f.OvulatoryDisorderFalse) * 1,
+		pick(has("diminished_ovarian_reserve"), f.DiminishedOvarianReserveTrue, f.DiminishedOvarianReserveFalse) * 1,
+		pick(has("uterine_factor"), f.UterineFactorTrue, f.UterineFactorFalse) * 1,
+		pick(has("other"), f.OtherReasonTrue, f.OtherReasonFalse) * 1,
+		pick(has("unexplained"), f.UnexplainedInfertilityTrue, f.UnexplainedInfertilityFalse) * 1,
+		count(req.PriorPregnancies, f.PriorPregnancies0, f.PriorPregnancies1, f.PriorPregnancies2Plus) * 1,
+		count(req.PriorBirths, f.PriorLiveBirths0, f.PriorLiveBirths1, f.PriorLiveBirths2Plus) * 1,
+	}
+	if len(f.RetrievalAdjustments) > 0 {
+		contributions = append(contributions, f.RetrievalAdjustments[req.retrievalCount()-1]*1)
+	}
+
+	logit := 0.0
+	for _, contribution := range contributions {
+		logit += contribution
+	}
+	return logit
+}
+
+// scenarioRequests returns the patients of the six calculator scenarios
+func scenarioRequests() []CalculateRequest {
+	weightLbs, heightFt, heightIn := getWeightHeightForBMI(22.8)
+	base := CalculateRequest{Age: 32, WeightLbs: weightLbs, HeightFt: heightFt, HeightIn: heightIn, PriorPregnancies: 1, PriorBirths: 1}
+	known := []string{"endometriosis", "ovulatory_disorder"}
+
+	var requests []CalculateRequest
+	for _, egg := range []struct{ source, priorIVF string }{{"own", "no"}, {"own", "yes"}, {"donor", ""}} {
+		for _, reasons := range [][]string{known, {"unknown"}} {
+			req := base
+			req.EggSource, req.PriorIvfCycles, req.Reasons = egg.source, egg.priorIVF, reasons
+			if egg.source == "donor" {
+				req.PriorPregnancies = 2
+			}
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+func TestFormulaDefinition_MatchesCSVFormulas(t *testing.T) {
+	// Give the formulas retrieval adjustments so every kind of term is covered
+	formulas := testStore(t).Formulas()
+	for i := range formulas {
+		formulas[i].RetrievalAdjustments = []float64{0, 0.4312, 0.7021}
+	}
+	store := NewFormulaStore(formulas)
+
+	patients := append(scenarioRequests(), StandardPatients()...)
+	for _, patient := range patients {
+		for retrievals := 1; retrievals <= 3; retrievals++ {
+			req := patient
+			req.Retrievals = retrievals
+
+			breakdown, err := explain(store, req)
+			if err != nil {
+				t.Fatalf("explain(%+v) returned error: %v", req, err)
+			}
+			formula := store.findMatchingFormula(req)
+			if formula.CDCFormula != breakdown.FormulaID {
+				t.Fatalf("Expected formula %s for %+v, got %s", formula.CDCFormula, req, breakdown.FormulaID)
+			}
+			if want := csvLogit(formula, req, breakdown.BMI); breakdown.Logit != want {
+				t.Fatalf("Expected logit %v for %+v, got %v", want, req, breakdown.Logit)
+			}
+		}
+	}
+}
+
+func TestWriteFormulaFile_RoundTrip(t *testing.T) {
+	csvStore := testStore(t)
+
+	var buf bytes.Buffer
+	if err := WriteFormulaFile(&buf, csvStore); err != nil {
+		t.Fatalf("WriteFormulaFile returned error: %v", err)
+	}
+	jsonStore, err := LoadFormulaDefinitions(&buf)
+	if err != nil {
+		t.Fatalf("LoadFormulaDefinitions returned error: %v", err)
+	}
+	if jsonStore.Len() != csvStore.Len() || jsonStore.Formulas() != nil {
+		t.Fatalf("Expected %d definitions and no CSV formulas, got %d and %d", csvStore.Len(), jsonStore.Len(), len(jsonStore.Formulas()))
+	}
+
+	for _, req := range append(scenarioRequests(), StandardPatients()...) {
+		want, err := explain(csvStore, req)
+		if err != nil {
+			t.Fatalf("explain(%+v) with CSV returned error: %v", req, err)
+		}
+		got, err := explain(jsonStore, req)
+		if err != nil {
+			t.Fatalf("explain(%+v) with JSON returned error: %v", req, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Expected identical breakdowns for %+v\nCSV:  %+v\nJSON: %+v", req, want, got)
+		}
+	}
+}
+
+func TestFormulaDefinition_CustomTerms(t *testing.T) {
+	// A covariate the CSV has no column for: a power term on prior births and
+	// a categorical term on age bands
+	store := NewDefinitionStore([]FormulaDefinition{{
+		ID:   "custom",
+		When: map[string]string{inputEggSource: "own", inputPriorBirths: "1+"},
+		Terms: []TermDefinition{
+			{Name: "intercept", Kind: TermIntercept, Coefficient: -1},
+			{Name: "births_power", Kind: TermPower, Input: inputPriorBirths, Coefficient: 0.5, Exponent: 2},
+			{Name: "age_band", Kind: TermCategorical, Input: inputAge, Levels: []LevelDefinition{
+				{Value: "35+", Coefficient: -0.25},
+				{Value: "40+", Coefficient: -1},
+				{Value: "42", Coefficient: -2},
+			}},
+		},
+	}})
+
+	tests := []struct {
+		age, births int
+		wantTerm    string
+		wantLogit   float64
+		wantErr     error
+	}{
+		{age: 36, births: 1, wantTerm: "age_band_35+", wantLogit: -1 + 0.5 - 0.25},
+		{age: 41, births: 2, wantTerm: "age_band_40+", wantLogit: -1 + 2 - 1},
+		{age: 42, births: 1, wantTerm: "age_band_42", wantLogit: -1 + 0.5 - 2},
+		{age: 30, births: 1, wantErr: ErrOutOfDomain},
+		{age: 36, births: 0, wantErr: ErrNoMatchingFormula},
+	}
+
+	for _, tt := range tests {
+		req := CalculateRequest{Age: tt.age, BMI: 22, EggSource: "own", PriorBirths: tt.births, Reasons: []string{"unknown"}}
+		breakdown, err := explain(store, req)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("age %d, births %d: expected error %v, got %v", tt.age, tt.births, tt.wantErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if got := breakdown.Terms[2].Name; got != tt.wantTerm {
+			t.Errorf("age %d: expected term %s, got %s", tt.age, tt.wantTerm, got)
+		}
+		if breakdown.Logit != tt.wantLogit {
+			t.Errorf("age %d, births %d: expected logit %v, got %v", tt.age, tt.births, tt.wantLogit, breakdown.Logit)
+		}
+	}
+}
+
+func TestLoadFormulaDefinitions_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{
+			name: "no formulas",
+			json: `{"formulas": []}`,
+			want: "no formulas defined",
+		},
+		{
+			name: "unknown input",
+			json: `{"formulas": [{"id": "1", "when": {"eggSorce": "own"}, "terms": [{"name": "intercept", "kind": "intercept"}]}]}`,
+			want: `formulas[0]: when eggSorce: unknown input "eggSorce"`,
+		},
+		{
+			name: "power term on a categorical input",
+			json: `{"formulas": [{"id": "1", "when": {}, "terms": [{"name": "egg", "kind": "power", "input": "eggSource", "exponent": 2}]}]}`,
+			want: `formulas[0].terms[0]: power terms need a numeric input, got "eggSource"`,
+		},
+		{
+			name: "invalid numeric level",
+			json: `{"formulas": [{"id": "1", "when": {}, "terms": [{"name": "births", "kind": "categorical", "input": "priorBirths", "levels": [{"value": "two"}]}]}]}`,
+			want: `formulas[0].terms[0].levels[0]: invalid level "two", expected a whole number or N+`,
+		},
+		{
+			name: "retrieval levels out of order",
+			json: `{"formulas": [{"id": "1", "when": {}, "terms": [{"name": "retrievals", "kind": "categorical", "input": "retrievals", "levels": [{"value": "1"}, {"value": "3"}]}]}]}`,
+			want: `formulas[0].terms[0].levels[1]: retrieval levels must be 1, 2, 3, ... in order, got "3"`,
+		},
+		{
+			name: "duplicate conditions",
+			json: `{"formulas": [
+				{"id": "1", "when": {"eggSource": "own"}, "terms": [{"name": "intercept", "kind": "intercept"}]},
+				{"id": "2", "when": {"eggSource": "own"}, "terms": [{"name": "intercept", "kind": "intercept"}]}
+			]}`,
+			want: "formulas[1]: formula 2 has the same conditions as formulas[0], so it would never apply",
+		},
+		{
+			name: "duplicate id",
+			json: `{"formulas": [
+				{"id": "1", "when": {"eggSource": "own"}, "terms": [{"name": "intercept", "kind": "intercept"}]},
+				{"id": "1", "when": {"eggSource": "donor"}, "terms": [{"name": "intercept", "kind": "intercept"}]}
+			]}`,
+			want: "formulas[1]: duplicate id 1, also used by formulas[0]",
+		},
+		{
+			name: "duplicate term",
+			json: `{"formulas": [{"id": "1", "when": {}, "terms": [{"name": "a", "kind": "intercept"}, {"name": "a", "kind": "intercept"}]}]}`,
+			want: "formulas[0].terms[1]: duplicate term a",
+		},
+		{
+			name: "unknown kind",
+			json: `{"formulas": [{"id": "1", "when": {}, "terms": [{"name": "a", "kind": "spline"}]}]}`,
+			want: `formulas[0].terms[0]: unknown kind "spline"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFormulaDefinitions(strings.NewReader(tt.json))
+			var report *LoadReport
+			if !errors.As(err, &report) {
+				t.Fatalf("Expected a *LoadReport, got %v", err)
+			}
+			if !strings.Contains(report.Error(), tt.want) {
+				t.Errorf("Expected a problem containing %q, got %v", tt.want, report)
+			}
+		})
+	}
+}
+
+func TestLoadFormulaDefinitions_TrailingData(t *testing.T) {
+	formulas := `{"formulas": [{"id": "1", "when": {}, "terms": [{"name": "intercept", "kind": "intercept"}]}]}`
+
+	if _, err := LoadFormulaDefinitions(strings.NewReader(formulas + "\n")); err != nil {
+		t.Fatalf("LoadFormulaDefinitions returned error: %v", err)
+	}
+	for _, trailing := range []string{`{"formulas": []}`, "]", "x"} {
+		_, err := LoadFormulaDefinitions(strings.NewReader(formulas + "\n" + trailing))
+		var report *LoadReport
+		if err == nil || errors.As(err, &report) {
+			t.Errorf("Expected a parse error for trailing %q, got %v", trailing, err)
+		}
+	}
+}
+
+func TestLoadFormulaDefinitions_UnknownField(t *testing.T) {
+	_, err := LoadFormulaDefinitions(strings.NewReader(`{"formulas": [], "formula": []}`))
+	var report *LoadReport
+	if err == nil || errors.As(err, &report) {
+		t.Fatalf("Expected a parse error, got %v", err)
+	}
+}
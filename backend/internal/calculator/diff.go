@@ -0,0 +1,309 @@
+package calculator
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+// CoefficientDiff is a coefficient whose value differs between two formulas.
+// Old or New is nil when the coefficient is only present in one of them.
+type CoefficientDiff struct {
+	Coefficient string   `json:"coefficient"`
+	Old         *float64 `json:"old"`
+	New         *float64 `json:"new"`
+}
+
+// FormulaDiff lists the coefficient changes of a formula present in both stores
+type FormulaDiff struct {
+	Parameters   string            `json:"parameters"`
+	OldFormulaID string            `json:"oldFormulaId"`
+	FormulaID    string            `json:"formulaId"`
+	Coefficients []CoefficientDiff `json:"coefficients"`
+}
+
+// Impact summarizes how much CumulativeChancePercent changed over a set of
+// patients. Patients counts the patients both stores calculate.
+type Impact struct {
+	FormulaID  string  `json:"formulaId,omitempty"`
+	Patients   int     `json:"patients"`
+	Changed    int     `json:"changed"`
+	MaxChange  float64 `json:"maxChange"`
+	MeanChange float64 `json:"meanChange"`
+	// MaxChangePatient is the first patient with the largest change
+	MaxChangePatient *CalculateRequest `json:"maxChangePatient,omitempty"`
+
+	// The patients only one store calculates, and those neither does. Only
+	// the overall impact counts them, since they have no formula in common.
+	NewlyFailing    int `json:"newlyFailing,omitempty"`
+	NewlyCalculated int `json:"newlyCalculated,omitempty"`
+	Skipped         int `json:"skipped,omitempty"`
+	// NewlyFailingPatient is the first patient the new store cannot calculate
+	NewlyFailingPatient *CalculateRequest `json:"newlyFailingPatient,omitempty"`
+}
+
+// StoreDiff describes the differences between two formula stores
+type StoreDiff struct {
+	// Added and Removed describe, by their parameters, formulas present in only one store
+	Added    []string      `json:"added,omitempty"`
+	Removed  []string      `json:"removed,omitempty"`
+	Formulas []FormulaDiff `json:"formulas"`
+	// Impact is the change over StandardPatients, overall and per formula of the new store
+	Impact          Impact   `json:"impact"`
+	ImpactByFormula []Impact `json:"impactByFormula"`
+}
+
+// Changed reports whether any formula or coefficient differs
+func (d StoreDiff) Changed() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Formulas) > 0
+}
+
+// DiffStores compares two formula stores, loaded from CSV or JSON. Formulas
+// are matched by the patient parameters they apply to rather than by id, so
+// renumbered formulas are compared correctly.
+func DiffStores(old, new *FormulaStore) StoreDiff {
+	diff := StoreDiff{Formulas: []FormulaDiff{}}
+
+	oldDefinitions := old.Definitions()
+	newByKey := definitionsByKey(new.Definitions())
+	matched := make(map[string]bool)
+
+	for i := range oldDefinitions {
+		oldFormula := &oldDefinitions[i]
+		key := whenKey(oldFormula.When)
+		newFormula, ok := newByKey[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, describeWhen(oldFormula.When))
+			continue
+		}
+		matched[key] = true
+
+		coefficients := diffCoefficients(oldFormula, newFormula)
+		if len(coefficients) > 0 || oldFormula.ID != newFormula.ID {
+			diff.Formulas = append(diff.Formulas, FormulaDiff{
+				Parameters:   describeWhen(oldFormula.When),
+				OldFormulaID: oldFormula.ID,
+				FormulaID:    newFormula.ID,
+				Coefficients: coefficients,
+			})
+		}
+	}
+	for _, newFormula := range new.Definitions() {
+		if !matched[whenKey(newFormula.When)] {
+			diff.Added = append(diff.Added, describeWhen(newFormula.When))
+		}
+	}
+
+	diff.Impact, diff.ImpactByFormula = impact(old, new, StandardPatients())
+	return diff
+}
+
+// diffCoefficients lists the coefficients that differ between two formulas,
+// named as in a Breakdown, with exponents of power terms suffixed
+// "_exponent". Coefficients of the old formula come first, in term order.
+func diffCoefficients(old, new *FormulaDefinition) []CoefficientDiff {
+	oldNames, oldValues := coefficientValues(old)
+	newNames, newValues := coefficientValues(new)
+
+	diffs := []CoefficientDiff{}
+	seen := make(map[string]bool)
+	for _, name := range append(oldNames, newNames...) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		oldVal, inOld := oldValues[name]
+		newVal, inNew := newValues[name]
+		if inOld && inNew && oldVal == newVal {
+			continue
+		}
+		d := CoefficientDiff{Coefficient: name}
+		if inOld {
+			d.Old = &oldVal
+		}
+		if inNew {
+			d.New = &newVal
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// coefficientValues returns the coefficient names of a formula in term order
+// and their values
+func coefficientValues(d *FormulaDefinition) ([]string, map[string]float64) {
+	var names []string
+	values := make(map[string]float64)
+	add := func(name string, value float64) {
+		names = append(names, name)
+		values[name] = value
+	}
+	for _, term := range d.Terms {
+		switch term.Kind {
+		case TermCategorical:
+			for _, level := range term.Levels {
+				add(term.Name+"_"+level.Value, level.Coefficient)
+			}
+		case TermPower:
+			add(term.Name, term.Coefficient)
+			add(term.Name+"_exponent", term.Exponent)
+		default:
+			add(term.Name, term.Coefficient)
+		}
+	}
+	return names, values
+}
+
+// impact computes the change in CumulativeChancePercent for every patient
+// both stores can calculate, and counts the patients either cannot
+func impact(old, new *FormulaStore, patients []CalculateRequest) (Impact, []Impact) {
+	overall := Impact{}
+	byFormula := make(map[string]*Impact)
+	var order []string
+
+	record := func(summary *Impact, change float64, patient CalculateRequest) {
+		summary.Patients++
+		if change != 0 {
+			summary.Changed++
+		}
+		summary.MeanChange += change
+		if change > summary.MaxChange {
+			summary.MaxChange = change
+			p := patient
+			summary.MaxChangePatient = &p
+		}
+	}
+
+	for _, patient := range patients {
+		oldBreakdown, oldErr := explain(old, patient)
+		newBreakdown, newErr := explain(new, patient)
+		switch {
+		case oldErr != nil && newErr != nil:
+			overall.Skipped++
+			continue
+		case oldErr != nil:
+			overall.NewlyCalculated++
+			continue
+		case newErr != nil:
+			overall.NewlyFailing++
+			if overall.NewlyFailingPatient == nil {
+				p := patient
+				overall.NewlyFailingPatient = &p
+			}
+			continue
+		}
+		change := math.Abs(toPercent(newBreakdown.Probability) - toPercent(oldBreakdown.Probability))
+
+		summary, ok := byFormula[newBreakdown.FormulaID]
+		if !ok {
+			summary = &Impact{FormulaID: newBreakdown.FormulaID}
+			byFormula[newBreakdown.FormulaID] = summary
+			order = append(order, newBreakdown.FormulaID)
+		}
+		record(summary, change, patient)
+		record(&overall, change, patient)
+	}
+
+	summaries := make([]Impact, 0, len(order))
+	for _, id := range order {
+		summary := byFormula[id]
+		summary.MeanChange /= float64(summary.Patients)
+		summaries = append(summaries, *summary)
+	}
+	if overall.Patients > 0 {
+		overall.MeanChange /= float64(overall.Patients)
+	}
+	return overall, summaries
+}
+
+// definitionsByKey indexes formulas by the parameters they apply to
+func definitionsByKey(definitions []FormulaDefinition) map[string]*FormulaDefinition {
+	byKey := make(map[string]*FormulaDefinition, len(definitions))
+	for i := range definitions {
+		byKey[whenKey(definitions[i].When)] = &definitions[i]
+	}
+	return byKey
+}
+
+// whenKey identifies the conditions of a formula independently of map order
+func whenKey(when map[string]string) string {
+	conditions := make([]string, 0, len(when))
+	for input, level := range when {
+		conditions = append(conditions, input+"="+level)
+	}
+	slices.Sort(conditions)
+	return strings.Join(conditions, ",")
+}
+
+// whenLabels describe the conditions the CDC formulas select on, in order
+var whenLabels = []struct {
+	input  string
+	labels map[string]string
+}{
+	{inputEggSource, map[string]string{"own": "own eggs", "donor": "donor eggs"}},
+	{inputPriorIvfCycles, map[string]string{"yes": "prior IVF", "no": "no prior IVF"}},
+	{inputReasonKnown, map[string]string{"true": "known reason", "false": "unknown reason"}},
+}
+
+// describeWhen describes the conditions of a formula, e.g. "own eggs, no prior
+// IVF, known reason". Other conditions follow as input=level.
+func describeWhen(when map[string]string) string {
+	var parts []string
+	described := make(map[string]bool)
+	for _, condition := range whenLabels {
+		if label, ok := condition.labels[when[condition.input]]; ok {
+			parts = append(parts, label)
+			described[condition.input] = true
+		}
+	}
+	var others []string
+	for input, level := range when {
+		if !described[input] {
+			others = append(others, input+"="+level)
+		}
+	}
+	slices.Sort(others)
+	return strings.Join(append(parts, others...), ", ")
+}
+
+// StandardPatients returns a grid of synthetic patients covering the valid
+// ranges of age and BMI, every formula, common reasons and pregnancy histories
+func StandardPatients() []CalculateRequest {
+	ages := []int{20, 25, 30, 35, 40, 45, 50}
+	bmis := []float64{18, 22, 26, 30, 35, 40}
+	eggs := []struct{ source, priorIVF string }{{"own", "no"}, {"own", "yes"}, {"donor", ""}}
+	reasons := [][]string{
+		{"unknown"},
+		{"unexplained"},
+		{"tubal_factor"},
+		{"male_factor_infertility"},
+		{"endometriosis", "ovulatory_disorder"},
+		{"diminished_ovarian_reserve"},
+		{"uterine_factor"},
+		{"other"},
+	}
+	histories := []struct{ pregnancies, births int }{{0, 0}, {1, 0}, {1, 1}, {2, 2}}
+
+	var patients []CalculateRequest
+	for _, age := range ages {
+		for _, bmi := range bmis {
+			for _, egg := range eggs {
+				for _, reason := range reasons {
+					for _, history := range histories {
+						patients = append(patients, CalculateRequest{
+							Age:              age,
+							BMI:              bmi,
+							EggSource:        egg.source,
+							PriorIvfCycles:   egg.priorIVF,
+							Reasons:          reason,
+							PriorPregnancies: history.pregnancies,
+							PriorBirths:      history.births,
+						})
+					}
+				}
+			}
+		}
+	}
+	return patients
+}
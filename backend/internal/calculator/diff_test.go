@@ -0,0 +1,138 @@
+package calculator
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffStores_Identical(t *testing.T) {
+	store := testStore(t)
+
+	diff := DiffStores(store, store)
+	if diff.Changed() {
+		t.Errorf("Expected no changes, got %+v", diff)
+	}
+	if diff.Impact.Patients != len(StandardPatients()) {
+		t.Errorf("Expected all %d patients to be calculated, got %d", len(StandardPatients()), diff.Impact.Patients)
+	}
+	if diff.Impact.Changed != 0 || diff.Impact.MaxChange != 0 || diff.Impact.MaxChangePatient != nil {
+		t.Errorf("Expected no impact, got %+v", diff.Impact)
+	}
+	if diff.Impact.NewlyFailing != 0 || diff.Impact.NewlyCalculated != 0 || diff.Impact.Skipped != 0 {
+		t.Errorf("Expected every patient to be calculated, got %+v", diff.Impact)
+	}
+	if len(diff.ImpactByFormula) != len(expectedFormulaKeys) {
+		t.Errorf("Expected impact for %d formulas, got %d", len(expectedFormulaKeys), len(diff.ImpactByFormula))
+	}
+}
+
+func TestDiffStores_ChangedCoefficient(t *testing.T) {
+	lines := readDefaultCSV(t)
+	lines[1] = replaceCell(t, lines[0], lines[1], "formula_intercept", "-6.7392144")
+
+	changed, err := LoadFormulas(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("Failed to load changed formulas: %v", err)
+	}
+
+	diff := DiffStores(testStore(t), changed)
+	if len(diff.Formulas) != 1 {
+		t.Fatalf("Expected 1 changed formula, got %+v", diff.Formulas)
+	}
+	formula := diff.Formulas[0]
+	if formula.FormulaID != "1-3" || len(formula.Coefficients) != 1 {
+		t.Fatalf("Expected one coefficient change in formula 1-3, got %+v", formula)
+	}
+	coefficient := formula.Coefficients[0]
+	if coefficient.Coefficient != "intercept" || *coefficient.Old != -6.8392144 || *coefficient.New != -6.7392144 {
+		t.Errorf("Unexpected coefficient diff %+v", coefficient)
+	}
+
+	if diff.Impact.Changed == 0 || diff.Impact.MaxChange <= 0 || diff.Impact.MeanChange <= 0 {
+		t.Errorf("Expected an impact, got %+v", diff.Impact)
+	}
+	if diff.Impact.MaxChangePatient == nil || diff.Impact.MaxChangePatient.EggSource != "own" {
+		t.Errorf("Expected the largest change for an own eggs patient, got %+v", diff.Impact.MaxChangePatient)
+	}
+	for _, impact := range diff.ImpactByFormula {
+		if impact.FormulaID != "1-3" && impact.Changed != 0 {
+			t.Errorf("Expected no change for formula %s, got %+v", impact.FormulaID, impact)
+		}
+	}
+}
+
+func TestDiffStores_Definitions(t *testing.T) {
+	csvStore := testStore(t)
+
+	// The definitions converted from the CSV are the same formulas
+	if diff := DiffStores(csvStore, NewDefinitionStore(csvStore.Definitions())); diff.Changed() || diff.Impact.Changed != 0 {
+		t.Errorf("Expected no changes, got %+v", diff)
+	}
+
+	definitions := csvStore.Definitions()
+	definitions[0].Terms = slices.Clone(definitions[0].Terms)
+	definitions[0].Terms[2].Exponent = 2.5
+	definitions[0].Terms = append(definitions[0].Terms, TermDefinition{Name: "age_cubic", Kind: TermPower, Input: inputAge, Coefficient: 0.00001, Exponent: 3})
+	definitions[5].When = map[string]string{inputEggSource: "donor", inputReasonKnown: "false", inputPriorBirths: "0"}
+
+	diff := DiffStores(csvStore, NewDefinitionStore(definitions))
+	if len(diff.Formulas) != 1 || diff.Formulas[0].FormulaID != "1-3" {
+		t.Fatalf("Expected formula 1-3 to change, got %+v", diff.Formulas)
+	}
+	var names []string
+	for _, coefficient := range diff.Formulas[0].Coefficients {
+		names = append(names, coefficient.Coefficient)
+	}
+	if want := []string{"age_power_exponent", "age_cubic", "age_cubic_exponent"}; !slices.Equal(names, want) {
+		t.Errorf("Expected changed coefficients %v, got %v", want, names)
+	}
+	if added := diff.Formulas[0].Coefficients[1]; added.Old != nil || *added.New != 0.00001 {
+		t.Errorf("Expected age_cubic to be added, got %+v", added)
+	}
+
+	if want := []string{"donor eggs, unknown reason"}; !slices.Equal(diff.Removed, want) {
+		t.Errorf("Expected removed %v, got %v", want, diff.Removed)
+	}
+	if want := []string{"donor eggs, unknown reason, priorBirths=0"}; !slices.Equal(diff.Added, want) {
+		t.Errorf("Expected added %v, got %v", want, diff.Added)
+	}
+}
+
+func TestDiffStores_RemovedFormula(t *testing.T) {
+	full := testStore(t)
+	definitions := full.Definitions()
+	removed := NewDefinitionStore(slices.Delete(slices.Clone(definitions), 5, 6))
+
+	// The patients of the removed formula fail under the new store, or the old
+	failing := 0
+	for _, patient := range StandardPatients() {
+		if _, err := explain(removed, patient); err != nil {
+			failing++
+		}
+	}
+	if failing == 0 {
+		t.Fatal("Expected patients without a formula")
+	}
+
+	diff := DiffStores(full, removed)
+	if diff.Impact.NewlyFailing != failing || diff.Impact.NewlyCalculated != 0 || diff.Impact.Skipped != 0 {
+		t.Errorf("Expected %d newly failing patients, got %+v", failing, diff.Impact)
+	}
+	if diff.Impact.Patients != len(StandardPatients())-failing {
+		t.Errorf("Expected %d patients to be calculated, got %d", len(StandardPatients())-failing, diff.Impact.Patients)
+	}
+	if p := diff.Impact.NewlyFailingPatient; p == nil || p.EggSource != "donor" || !slices.Equal(p.Reasons, []string{"unknown"}) {
+		t.Errorf("Expected a donor eggs patient with an unknown reason to fail, got %+v", p)
+	}
+
+	diff = DiffStores(removed, full)
+	if diff.Impact.NewlyCalculated != failing || diff.Impact.NewlyFailing != 0 || diff.Impact.NewlyFailingPatient != nil {
+		t.Errorf("Expected %d newly calculated patients, got %+v", failing, diff.Impact)
+	}
+
+	diff = DiffStores(removed, removed)
+	if diff.Impact.Skipped != failing || diff.Impact.NewlyFailing != 0 || diff.Impact.NewlyCalculated != 0 {
+		t.Errorf("Expected %d skipped patients, got %+v", failing, diff.Impact)
+	}
+}
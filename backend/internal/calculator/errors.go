@@ -0,0 +1,35 @@
+package calculator
+
+import "errors"
+
+// Sentinel errors returned by Calculate and Explain. Callers should match them
+// with errors.Is since they are usually wrapped with additional context.
+var (
+	// ErrNoFormulas is returned when no formulas could be loaded
+	ErrNoFormulas = errors.New("no formulas loaded")
+
+	// ErrNoMatchingFormula is returned when no formula matches the patient parameters
+	ErrNoMatchingFormula = errors.New("no matching formula found")
+
+	// ErrUnknownModel is returned when the requested prediction model is not registered
+	ErrUnknownModel = errors.New("unknown model")
+
+	// ErrUnknownModelVersion is returned when the requested formula version is not loaded
+	ErrUnknownModelVersion = errors.New("unknown model version")
+
+	// ErrUnsupportedRetrievals is returned when the formula cannot estimate the
+	// requested number of retrievals
+	ErrUnsupportedRetrievals = errors.New("unsupported number of retrievals")
+
+	// ErrVersionConflict is returned by SetStore when a selectable store has
+	// the same version but other formulas
+	ErrVersionConflict = errors.New("formula version already loaded with other formulas")
+
+	// ErrOutOfDomain is returned when an input cannot be evaluated by the formula
+	ErrOutOfDomain = errors.New("input outside formula domain")
+)
+
+// ErrInvalidOption is returned by the Calculator's Set methods for settings
+// that cannot be used. Unlike the errors above it is a configuration problem,
+// not a problem with a request.
+var ErrInvalidOption = errors.New("invalid calculator option")
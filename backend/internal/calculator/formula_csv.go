@@ -0,0 +1,326 @@
+package calculator
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Parameter columns select which formula applies to a patient
+const (
+	colUsingOwnEggs  = "param_using_own_eggs"
+	colAttemptedIVF  = "param_attempted_ivf_previously"
+	colIsReasonKnown = "param_is_reason_for_infertility_known"
+	colCDCFormula    = "cdc_formula"
+)
+
+// Accepted values for the boolean parameter columns
+const (
+	boolTrue          = "TRUE"
+	boolFalse         = "FALSE"
+	boolNotApplicable = "N/A"
+)
+
+// coefficientColumn maps a numeric CSV column to its Formula field
+type coefficientColumn struct {
+	name  string
+	field func(f *Formula) *float64
+}
+
+// coefficientColumns lists every numeric column of the formula CSV in file order
+var coefficientColumns = []coefficientColumn{
+	{"formula_intercept", func(f *Formula) *float64 { return &f.Intercept }},
+	{"formula_age_linear_coefficient", func(f *Formula) *float64 { return &f.AgeLinearCoeff }},
+	{"formula_age_power_coefficient", func(f *Formula) *float64 { return &f.AgePowerCoeff }},
+	{"formula_age_power_factor", func(f *Formula) *float64 { return &f.AgePowerFactor }},
+	{"formula_bmi_linear_coefficient", func(f *Formula) *float64 { return &f.BMILinearCoeff }},
+	{"formula_bmi_power_coefficient", func(f *Formula) *float64 { return &f.BMIPowerCoeff }},
+	{"formula_bmi_power_factor", func(f *Formula) *float64 { return &f.BMIPowerFactor }},
+	{"formula_tubal_factor_true_value", func(f *Formula) *float64 { return &f.TubalFactorTrue }},
+	{"formula_tubal_factor_false_value", func(f *Formula) *float64 { return &f.TubalFactorFalse }},
+	{"formula_male_factor_infertility_true_value", func(f *Formula) *float64 { return &f.MaleFactorInfertilityTrue }},
+	{"formula_male_factor_infertility_false_value", func(f *Formula) *float64 { return &f.MaleFactorInfertilityFalse }},
+	{"formula_endometriosis_true_value", func(f *Formula) *float64 { return &f.EndometriosisTrue }},
+	{"formula_endometriosis_false_value", func(f *Formula) *float64 { return &f.EndometriosisFalse }},
+	{"formula_ovulatory_disorder_true_value", func(f *Formula) *float64 { return &f.OvulatoryDisorderTrue }},
+	{"formula_ovulatory_disorder_false_value", func(f *Formula) *float64 { return &f.OvulatoryDisorderFalse }},
+	{"formula_diminished_ovarian_reserve_true_value", func(f *Formula) *float64 { return &f.DiminishedOvarianReserveTrue }},
+	{"formula_diminished_ovarian_reserve_false_value", func(f *Formula) *float64 { return &f.DiminishedOvarianReserveFalse }},
+	{"formula_uterine_factor_true_value", func(f *Formula) *float64 { return &f.UterineFactorTrue }},
+	{"formula_uterine_factor_false_value", func(f *Formula) *float64 { return &f.UterineFactorFalse }},
+	{"formula_other_reason_true_value", func(f *Formula) *float64 { return &f.OtherReasonTrue }},
+	{"formula_other_reason_false_value", func(f *Formula) *float64 { return &f.OtherReasonFalse }},
+	{"formula_unexplained_infertility_true_value", func(f *Formula) *float64 { return &f.UnexplainedInfertilityTrue }},
+	{"formula_unexplained_infertility_false_value", func(f *Formula) *float64 { return &f.UnexplainedInfertilityFalse }},
+	{"formula_prior_pregnancies_0_value", func(f *Formula) *float64 { return &f.PriorPregnancies0 }},
+	{"formula_prior_pregnancies_1_value", func(f *Formula) *float64 { return &f.PriorPregnancies1 }},
+	{"formula_prior_pregnancies_2+_value", func(f *Formula) *float64 { return &f.PriorPregnancies2Plus }},
+	{"formula_prior_live_births_0_value", func(f *Formula) *float64 { return &f.PriorLiveBirths0 }},
+	{"formula_prior_live_births_1_value", func(f *Formula) *float64 { return &f.PriorLiveBirths1 }},
+	{"formula_prior_live_births_2+_value", func(f *Formula) *float64 { return &f.PriorLiveBirths2Plus }},
+}
+
+// retrievalColumns are optional logit adjustments for 1, 2 and 3 intended egg
+// retrievals. A file either has all of them or none, in which case its
+// formulas only estimate a single retrieval.
+var retrievalColumns = []string{
+	"formula_retrievals_1_value",
+	"formula_retrievals_2_value",
+	"formula_retrievals_3_value",
+}
+
+// requiredColumns returns every column the formula CSV must contain
+func requiredColumns() []string {
+	columns := []string{colUsingOwnEggs, colAttemptedIVF, colIsReasonKnown, colCDCFormula}
+	for _, col := range coefficientColumns {
+		columns = append(columns, col.name)
+	}
+	return columns
+}
+
+// LoadProblem describes a single problem found while loading a formula CSV.
+// Row is the line number in the file, or 0 for problems with the file as a whole.
+type LoadProblem struct {
+	Row     int
+	Column  string
+	Message string
+}
+
+func (p LoadProblem) String() string {
+	var location []string
+	if p.Row > 0 {
+		location = append(location, fmt.Sprintf("row %d", p.Row))
+	}
+	if p.Column != "" {
+		location = append(location, fmt.Sprintf("column %s", p.Column))
+	}
+	if len(location) == 0 {
+		return p.Message
+	}
+	return strings.Join(location, ", ") + ": " + p.Message
+}
+
+// LoadReport lists every problem found while loading a formula file. It is
+// returned as the error from LoadFormulas and LoadFormulaDefinitions when the
+// file fails validation.
+type LoadReport struct {
+	Problems []LoadProblem
+}
+
+func (r *LoadReport) Error() string {
+	lines := make([]string, len(r.Problems))
+	for i, p := range r.Problems {
+		lines[i] = p.String()
+	}
+	return fmt.Sprintf("invalid formula file (%d problems): %s", len(r.Problems), strings.Join(lines, "; "))
+}
+
+func (r *LoadReport) add(row int, column, format string, args ...any) {
+	r.Problems = append(r.Problems, LoadProblem{Row: row, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// formulaKey identifies the patient parameters a formula applies to
+type formulaKey struct {
+	usingOwnEggs  bool
+	attemptedIVF  string
+	isReasonKnown bool
+}
+
+// expectedFormulaKeys lists the combinations that must each have exactly one
+// formula: own eggs with and without prior IVF, and donor eggs, each with a
+// known or unknown reason
+var expectedFormulaKeys = []formulaKey{
+	{true, boolFalse, true},
+	{true, boolFalse, false},
+	{true, boolTrue, true},
+	{true, boolTrue, false},
+	{false, boolNotApplicable, true},
+	{false, boolNotApplicable, false},
+}
+
+// LoadFormulas reads and parses CSV data containing IVF success formulas. The
+// data is validated strictly: every problem found is collected into a
+// *LoadReport which is returned as the error.
+func LoadFormulas(r io.Reader) (*FormulaStore, error) {
+	hash := sha256.New()
+	reader := csv.NewReader(io.TeeReader(r, hash))
+
+	// Read header
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	// Create a map of column indices
+	colIndex := make(map[string]int)
+	for i, col := range header {
+		colIndex[strings.TrimSpace(col)] = i
+	}
+
+	report := &LoadReport{}
+	for _, col := range requiredColumns() {
+		if _, ok := colIndex[col]; !ok {
+			report.add(1, col, "missing required column")
+		}
+	}
+
+	hasRetrievals := false
+	for _, col := range retrievalColumns {
+		if _, ok := colIndex[col]; ok {
+			hasRetrievals = true
+		}
+	}
+	if hasRetrievals {
+		for _, col := range retrievalColumns {
+			if _, ok := colIndex[col]; !ok {
+				report.add(1, col, "missing retrieval column (all or none of %s are required)", strings.Join(retrievalColumns, ", "))
+			}
+		}
+	}
+
+	// Without every column the rows cannot be interpreted
+	if len(report.Problems) > 0 {
+		return nil, report
+	}
+
+	var formulas []Formula
+	seen := make(map[formulaKey]int)
+
+	// Read data rows
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read CSV record: %w", err)
+			}
+			if errors.Is(parseErr.Err, csv.ErrFieldCount) {
+				report.add(parseErr.StartLine, "", "expected %d fields, got %d", len(header), len(record))
+			} else {
+				report.add(parseErr.StartLine, "", "%v", parseErr.Err)
+			}
+			continue
+		}
+		// FieldPos is only valid after a successful read
+		row, _ := reader.FieldPos(0)
+
+		formula, key, ok := parseFormulaRow(record, colIndex, hasRetrievals, row, report)
+
+		// A row whose parameters failed to parse would only produce misleading
+		// duplicate or missing combination errors
+		if !ok {
+			continue
+		}
+
+		if first, ok := seen[key]; ok {
+			report.add(row, "", "duplicate formula for own eggs=%t, attempted IVF=%s, reason known=%t (first defined on row %d)",
+				key.usingOwnEggs, key.attemptedIVF, key.isReasonKnown, first)
+		} else {
+			seen[key] = row
+		}
+
+		formulas = append(formulas, formula)
+	}
+
+	for _, key := range expectedFormulaKeys {
+		if _, ok := seen[key]; !ok {
+			report.add(0, "", "missing formula for own eggs=%t, attempted IVF=%s, reason known=%t",
+				key.usingOwnEggs, key.attemptedIVF, key.isReasonKnown)
+		}
+	}
+
+	if len(report.Problems) > 0 {
+		return nil, report
+	}
+
+	store := NewFormulaStore(formulas)
+	store.checksum = hex.EncodeToString(hash.Sum(nil))
+	return store, nil
+}
+
+// parseFormulaRow converts a CSV record into a Formula, adding any problems to
+// report. ok is false when the parameter columns could not be parsed.
+func parseFormulaRow(record []string, colIndex map[string]int, hasRetrievals bool, row int, report *LoadReport) (formula Formula, key formulaKey, ok bool) {
+	before := len(report.Problems)
+	cell := func(col string) string {
+		return strings.TrimSpace(record[colIndex[col]])
+	}
+
+	// Parse boolean parameters
+	formula.UsingOwnEggs = parseBool(cell(colUsingOwnEggs), row, colUsingOwnEggs, report)
+	formula.IsReasonKnown = parseBool(cell(colIsReasonKnown), row, colIsReasonKnown, report)
+
+	attemptedIVFStr := cell(colAttemptedIVF)
+	switch attemptedIVFStr {
+	case boolNotApplicable:
+		if formula.UsingOwnEggs {
+			report.add(row, colAttemptedIVF, "must be %s or %s when using own eggs", boolTrue, boolFalse)
+		}
+		formula.AttemptedIVFPreviously = nil
+	case boolTrue, boolFalse:
+		if !formula.UsingOwnEggs {
+			report.add(row, colAttemptedIVF, "must be %s when using donor eggs", boolNotApplicable)
+		}
+		val := attemptedIVFStr == boolTrue
+		formula.AttemptedIVFPreviously = &val
+	default:
+		report.add(row, colAttemptedIVF, "invalid value %q, expected %s, %s or %s", attemptedIVFStr, boolTrue, boolFalse, boolNotApplicable)
+	}
+
+	key = formulaKey{
+		usingOwnEggs:  formula.UsingOwnEggs,
+		attemptedIVF:  attemptedIVFStr,
+		isReasonKnown: formula.IsReasonKnown,
+	}
+	ok = len(report.Problems) == before
+
+	formula.CDCFormula = cell(colCDCFormula)
+	if formula.CDCFormula == "" {
+		report.add(row, colCDCFormula, "must not be empty")
+	}
+
+	// Parse numeric coefficients
+	for _, col := range coefficientColumns {
+		*col.field(&formula) = parseFloat(cell(col.name), row, col.name, report)
+	}
+
+	if hasRetrievals {
+		formula.RetrievalAdjustments = make([]float64, len(retrievalColumns))
+		for i, col := range retrievalColumns {
+			formula.RetrievalAdjustments[i] = parseFloat(cell(col), row, col, report)
+		}
+	}
+
+	return formula, key, ok
+}
+
+// parseBool parses a TRUE/FALSE cell
+func parseBool(s string, row int, column string, report *LoadReport) bool {
+	switch s {
+	case boolTrue:
+		return true
+	case boolFalse:
+		return false
+	}
+	report.add(row, column, "invalid value %q, expected %s or %s", s, boolTrue, boolFalse)
+	return false
+}
+
+// parseFloat parses a finite numeric cell
+func parseFloat(s string, row int, column string, report *LoadReport) float64 {
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+		report.add(row, column, "invalid number %q", s)
+		return 0
+	}
+	return val
+}
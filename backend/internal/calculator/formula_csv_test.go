@@ -0,0 +1,145 @@
+package calculator
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readDefaultCSV returns the embedded formula CSV split into lines
+func readDefaultCSV(t *testing.T) []string {
+	t.Helper()
+	file, err := defaultFormulaFS.Open(defaultFormulaFile)
+	if err != nil {
+		t.Fatalf("Failed to open embedded CSV: %v", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("Failed to read embedded CSV: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// replaceCell swaps the value of column in a CSV line
+func replaceCell(t *testing.T, header, line, column, value string) string {
+	t.Helper()
+	cols := strings.Split(header, ",")
+	cells := strings.Split(line, ",")
+	for i, col := range cols {
+		if col == column {
+			cells[i] = value
+			return strings.Join(cells, ",")
+		}
+	}
+	t.Fatalf("Column %s not found", column)
+	return ""
+}
+
+func TestLoadFormulas_Validation(t *testing.T) {
+	lines := readDefaultCSV(t)
+	header := lines[0]
+
+	tests := []struct {
+		name   string
+		mutate func(lines []string) []string
+		want   []LoadProblem
+	}{
+		{
+			name: "missing column",
+			mutate: func(lines []string) []string {
+				lines[0] = strings.Replace(lines[0], "formula_intercept", "formula_intercpt", 1)
+				return lines
+			},
+			want: []LoadProblem{{Row: 1, Column: "formula_intercept", Message: "missing required column"}},
+		},
+		{
+			name: "malformed coefficient",
+			mutate: func(lines []string) []string {
+				lines[2] = replaceCell(t, header, lines[2], "formula_age_linear_coefficient", "0.37O")
+				return lines
+			},
+			want: []LoadProblem{{Row: 3, Column: "formula_age_linear_coefficient", Message: `invalid number "0.37O"`}},
+		},
+		{
+			name: "invalid boolean",
+			mutate: func(lines []string) []string {
+				lines[1] = replaceCell(t, header, lines[1], colIsReasonKnown, "yes")
+				return lines
+			},
+			want: []LoadProblem{
+				{Row: 2, Column: colIsReasonKnown, Message: `invalid value "yes", expected TRUE or FALSE`},
+				{Row: 0, Message: "missing formula for own eggs=true, attempted IVF=FALSE, reason known=true"},
+			},
+		},
+		{
+			name: "duplicate and missing combination",
+			mutate: func(lines []string) []string {
+				lines[2] = replaceCell(t, header, lines[2], colIsReasonKnown, "TRUE")
+				return lines
+			},
+			want: []LoadProblem{
+				{Row: 3, Message: "duplicate formula for own eggs=true, attempted IVF=FALSE, reason known=true (first defined on row 2)"},
+				{Row: 0, Message: "missing formula for own eggs=true, attempted IVF=FALSE, reason known=false"},
+			},
+		},
+		{
+			name: "partial retrieval columns",
+			mutate: func(lines []string) []string {
+				lines[0] += ",formula_retrievals_1_value"
+				for i := 1; i < len(lines); i++ {
+					lines[i] += ",0"
+				}
+				return lines
+			},
+			want: []LoadProblem{
+				{Row: 1, Column: "formula_retrievals_2_value", Message: "missing retrieval column (all or none of formula_retrievals_1_value, formula_retrievals_2_value, formula_retrievals_3_value are required)"},
+				{Row: 1, Column: "formula_retrievals_3_value", Message: "missing retrieval column (all or none of formula_retrievals_1_value, formula_retrievals_2_value, formula_retrievals_3_value are required)"},
+			},
+		},
+		{
+			name: "wrong field count",
+			mutate: func(lines []string) []string {
+				lines[6] += ",1"
+				return lines
+			},
+			want: []LoadProblem{
+				{Row: 7, Message: "expected 33 fields, got 34"},
+				{Row: 0, Message: "missing formula for own eggs=false, attempted IVF=N/A, reason known=false"},
+			},
+		},
+		{
+			name: "bad quote",
+			mutate: func(lines []string) []string {
+				lines[3] = replaceCell(t, header, lines[3], "formula_intercept", `a"b`)
+				return lines
+			},
+			want: []LoadProblem{
+				{Row: 4, Message: `bare " in non-quoted-field`},
+				{Row: 0, Message: "missing formula for own eggs=true, attempted IVF=TRUE, reason known=true"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutated := tt.mutate(append([]string(nil), lines...))
+			_, err := LoadFormulas(strings.NewReader(strings.Join(mutated, "\n")))
+
+			var report *LoadReport
+			if !errors.As(err, &report) {
+				t.Fatalf("Expected *LoadReport, got %v", err)
+			}
+			if len(report.Problems) != len(tt.want) {
+				t.Fatalf("Expected %d problems, got %v", len(tt.want), report.Problems)
+			}
+			for i, want := range tt.want {
+				if report.Problems[i] != want {
+					t.Errorf("Problem %d = %+v, want %+v", i, report.Problems[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package calculator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"strconv"
+)
+
+// LoadFormulaDefinitions reads a FormulaFile in JSON. Like LoadFormulas, every
+// problem found is collected into a *LoadReport which is returned as the error.
+func LoadFormulaDefinitions(r io.Reader) (*FormulaStore, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read formula JSON: %w", err)
+	}
+
+	var file FormulaFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse formula JSON: %w", err)
+	}
+	// A second value would be silently ignored, and is most likely a bad merge
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return nil, fmt.Errorf("failed to parse formula JSON: unexpected data after the formula file at offset %d", decoder.InputOffset())
+	}
+
+	if report := validateDefinitions(file.Formulas); len(report.Problems) > 0 {
+		return nil, report
+	}
+
+	store := NewDefinitionStore(file.Formulas)
+	sum := sha256.Sum256(data)
+	store.checksum = hex.EncodeToString(sum[:])
+	store.version = file.Version
+	return store, nil
+}
+
+// WriteFormulaFile writes the formulas of store as an indented JSON FormulaFile
+func WriteFormulaFile(w io.Writer, store *FormulaStore) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(FormulaFile{Version: store.version, Formulas: store.Definitions()})
+}
+
+// validateDefinitions checks that every formula can be evaluated. Problems are
+// located by their path in the file, as definitions have no rows.
+func validateDefinitions(formulas []FormulaDefinition) *LoadReport {
+	report := &LoadReport{}
+	if len(formulas) == 0 {
+		report.add(0, "", "no formulas defined")
+	}
+
+	ids := make(map[string]int)
+	for i, formula := range formulas {
+		path := "formulas[" + strconv.Itoa(i) + "]"
+		if formula.ID == "" {
+			report.add(0, "", "%s: id must not be empty", path)
+		} else if j, ok := ids[formula.ID]; ok {
+			// Formulas are looked up by id, e.g. to simulate the one a patient matched
+			report.add(0, "", "%s: duplicate id %s, also used by formulas[%d]", path, formula.ID, j)
+		} else {
+			ids[formula.ID] = i
+		}
+		for input, value := range formula.When {
+			if problem := checkLevel(input, value); problem != "" {
+				report.add(0, "", "%s: when %s: %s", path, input, problem)
+			}
+		}
+		for j := range formulas[:i] {
+			if maps.Equal(formulas[j].When, formula.When) {
+				report.add(0, "", "%s: formula %s has the same conditions as formulas[%d], so it would never apply", path, formula.ID, j)
+				break
+			}
+		}
+		if len(formula.Terms) == 0 {
+			report.add(0, "", "%s: no terms defined", path)
+		}
+
+		names := make(map[string]bool)
+		for j, term := range formula.Terms {
+			termPath := fmt.Sprintf("%s.terms[%d]", path, j)
+			if term.Name == "" {
+				report.add(0, "", "%s: name must not be empty", termPath)
+			} else if names[term.Name] {
+				report.add(0, "", "%s: duplicate term %s", termPath, term.Name)
+			}
+			names[term.Name] = true
+			validateTerm(term, termPath, report)
+		}
+		validateCovariance(formula, path, report)
+	}
+	return report
+}
+
+// validateCovariance checks that the covariance matrix of a formula names its
+// coefficients, is symmetric and is positive semidefinite
+func validateCovariance(formula FormulaDefinition, path string, report *LoadReport) {
+	if len(formula.Covariance) == 0 {
+		return
+	}
+
+	names := formula.coefficientNames()
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+
+	before := len(report.Problems)
+	for a, row := range formula.Covariance {
+		for b, v := range row {
+			_, aOK := index[a]
+			_, bOK := index[b]
+			switch {
+			case !aOK || !bOK:
+				unknown := a
+				if aOK {
+					unknown = b
+				}
+				report.add(0, "", "%s.covariance: unknown coefficient %q", path, unknown)
+			case a == b && v < 0:
+				report.add(0, "", "%s.covariance: variance of %s must not be negative, got %v", path, a, v)
+			case a < b:
+				if other, ok := formula.Covariance[b][a]; ok && other != v {
+					report.add(0, "", "%s.covariance: %s, %s is %v but %s, %s is %v", path, a, b, v, b, a, other)
+				}
+			}
+		}
+	}
+	if len(report.Problems) > before {
+		return
+	}
+
+	matrix := make([][]float64, len(names))
+	for i, a := range names {
+		matrix[i] = make([]float64, len(names))
+		for j, b := range names {
+			matrix[i][j] = formula.covariance(a, b)
+		}
+	}
+	if !positiveSemidefinite(matrix) {
+		report.add(0, "", "%s.covariance: matrix is not positive semidefinite", path)
+	}
+}
+
+// validateTerm checks the input and levels of a term against its kind
+func validateTerm(term TermDefinition, path string, report *LoadReport) {
+	switch term.Kind {
+	case TermIntercept:
+		if term.Input != "" {
+			report.add(0, "", "%s: intercept terms have no input", path)
+		}
+	case TermLinear, TermPower:
+		if !isNumericInput(term.Input) {
+			report.add(0, "", "%s: %s terms need a numeric input, got %q", path, term.Kind, term.Input)
+		}
+	case TermCategorical:
+		if len(term.Levels) == 0 {
+			report.add(0, "", "%s: categorical terms need at least one level", path)
+		}
+		seen := make(map[string]bool)
+		for k, level := range term.Levels {
+			if seen[level.Value] {
+				report.add(0, "", "%s: duplicate level %q", path, level.Value)
+			}
+			seen[level.Value] = true
+			if problem := checkLevel(term.Input, level.Value); problem != "" {
+				report.add(0, "", "%s.levels[%d]: %s", path, k, problem)
+			}
+			// Retrievals are estimated one by one from 1, see MaxRetrievals
+			if term.Input == inputRetrievals && level.Value != strconv.Itoa(k+1) {
+				report.add(0, "", "%s.levels[%d]: retrieval levels must be 1, 2, 3, ... in order, got %q", path, k, level.Value)
+			}
+		}
+	default:
+		report.add(0, "", "%s: unknown kind %q, expected %s, %s, %s or %s", path, term.Kind, TermIntercept, TermLinear, TermPower, TermCategorical)
+	}
+}
+
+// checkLevel describes why value is not a valid level of input, or returns an
+// empty string if it is
+func checkLevel(input, value string) string {
+	switch {
+	case isCategoricalInput(input):
+		if value == "" {
+			return "level must not be empty"
+		}
+	case isNumericInput(input):
+		if _, _, err := parseLevel(value); err != nil {
+			return err.Error()
+		}
+	default:
+		return fmt.Sprintf("unknown input %q", input)
+	}
+	return ""
+}
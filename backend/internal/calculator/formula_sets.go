@@ -0,0 +1,113 @@
+package calculator
+
+import (
+	"fmt"
+	"slices"
+)
+
+// VersionInfo describes a formula store available to a Calculator
+type VersionInfo struct {
+	Version  string `json:"version"`
+	Checksum string `json:"checksum"`
+	Formulas int    `json:"formulas"`
+	Latest   bool   `json:"latest"`
+}
+
+// formulaSets is an immutable snapshot of the stores a Calculator can select.
+// Changes produce a new snapshot so readers never observe a partial update.
+type formulaSets struct {
+	latest    *FormulaStore
+	byVersion map[string]*FormulaStore
+	order     []string
+	replaced  []string // versions of stores replaced as the latest, oldest first
+}
+
+// with returns a copy of the snapshot including store, optionally as the latest
+func (s *formulaSets) with(store *FormulaStore, latest bool) *formulaSets {
+	next := &formulaSets{
+		latest:    s.latest,
+		byVersion: make(map[string]*FormulaStore, len(s.byVersion)+1),
+		order:     append([]string(nil), s.order...),
+		replaced:  append([]string(nil), s.replaced...),
+	}
+	for version, existing := range s.byVersion {
+		next.byVersion[version] = existing
+	}
+
+	if latest {
+		next.latest = store
+	}
+	if store == nil {
+		return next
+	}
+
+	version := store.Version()
+	if _, ok := next.byVersion[version]; !ok {
+		next.order = append(next.order, version)
+	}
+	next.byVersion[version] = store
+	return next
+}
+
+// replace returns a copy of the snapshot with store as the latest. The store it
+// replaces stays selectable by version, but only the retain most recently
+// replaced stores are kept; stores that were never the latest are kept always.
+// A store whose version is selectable with another checksum is rejected, since
+// results quoting that version could no longer be reproduced.
+func (s *formulaSets) replace(store *FormulaStore, retain int) (*formulaSets, error) {
+	if existing, ok := s.byVersion[store.Version()]; ok && existing.Checksum() != store.Checksum() {
+		return nil, fmt.Errorf("%w: version %s has checksum %s, got %s", ErrVersionConflict, store.Version(), existing.Checksum(), store.Checksum())
+	}
+
+	next := s.with(store, true)
+	next.replaced = slices.DeleteFunc(next.replaced, func(version string) bool {
+		return version == store.Version()
+	})
+	if s.latest != nil && s.latest.Version() != store.Version() {
+		next.replaced = append(next.replaced, s.latest.Version())
+	}
+
+	for len(next.replaced) > retain {
+		evicted := next.replaced[0]
+		next.replaced = next.replaced[1:]
+		delete(next.byVersion, evicted)
+		next.order = slices.DeleteFunc(next.order, func(version string) bool { return version == evicted })
+	}
+	return next, nil
+}
+
+// lookup returns the store for version, or the latest store when version is empty
+func (s *formulaSets) lookup(version string) (*FormulaStore, error) {
+	if version == "" {
+		return s.latest, nil
+	}
+	store, ok := s.byVersion[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownModelVersion, version)
+	}
+	return store, nil
+}
+
+// maxRetrievals returns the highest number of intended retrievals any store
+// can estimate
+func (s *formulaSets) maxRetrievals() int {
+	most := 1
+	for _, store := range s.byVersion {
+		most = max(most, store.MaxRetrievals())
+	}
+	return most
+}
+
+func (s *formulaSets) info() []VersionInfo {
+	versions := make([]VersionInfo, 0, len(s.order))
+	for _, version := range s.order {
+		store := s.byVersion[version]
+		versions = append(versions, VersionInfo{
+			Version:  version,
+			Checksum: store.Checksum(),
+			Formulas: store.Len(),
+			Latest:   store == s.latest,
+		})
+	}
+	return versions
+}
@@ -0,0 +1,178 @@
+package calculator
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// defaultFormulaFile is the CDC formula CSV embedded in the binary
+const defaultFormulaFile = "ivf_success_formulas.csv"
+
+// versionChecksumLength is how many checksum characters name an unversioned store
+const versionChecksumLength = 12
+
+//go:embed ivf_success_formulas.csv
+var defaultFormulaFS embed.FS
+
+// FormulaStore holds a set of formulas loaded from a single source. A store is
+// not modified after it is loaded, so it can be shared between goroutines.
+type FormulaStore struct {
+	// definitions are evaluated by the calculator. Stores loaded from a CSV
+	// also keep its formulas, definitions[i] being converted from formulas[i].
+	definitions []FormulaDefinition
+	formulas    []Formula
+	checksum    string
+	version     string
+}
+
+// NewFormulaStore creates a store from already parsed formulas
+func NewFormulaStore(formulas []Formula) *FormulaStore {
+	store := &FormulaStore{formulas: append([]Formula(nil), formulas...)}
+	for i := range store.formulas {
+		store.definitions = append(store.definitions, store.formulas[i].Definition())
+	}
+	return store
+}
+
+// NewDefinitionStore creates a store from formula definitions
+func NewDefinitionStore(definitions []FormulaDefinition) *FormulaStore {
+	return &FormulaStore{definitions: append([]FormulaDefinition(nil), definitions...)}
+}
+
+// DefaultFormulaStore loads the CDC formulas embedded in the binary
+func DefaultFormulaStore() (*FormulaStore, error) {
+	return LoadFormulasFS(defaultFormulaFS, defaultFormulaFile)
+}
+
+// LoadFormulasFile loads formulas from the file at path: a FormulaFile if it
+// has a .json extension, a formula CSV otherwise
+func LoadFormulasFile(path string) (*FormulaStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open formula file: %w", err)
+	}
+	defer file.Close()
+
+	return loadFormulas(file, path)
+}
+
+// LoadFormulasFS loads formulas from the named file in fsys, like LoadFormulasFile
+func LoadFormulasFS(fsys fs.FS, name string) (*FormulaStore, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open formula file: %w", err)
+	}
+	defer file.Close()
+
+	return loadFormulas(file, name)
+}
+
+// loadFormulas parses r in the format named by the extension of name
+func loadFormulas(r io.Reader, name string) (*FormulaStore, error) {
+	if IsFormulaFile(name) {
+		return LoadFormulaDefinitions(r)
+	}
+	return LoadFormulas(r)
+}
+
+// IsFormulaFile reports whether name is a JSON FormulaFile rather than a CSV
+func IsFormulaFile(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".json")
+}
+
+// Len returns the number of formulas in the store
+func (s *FormulaStore) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.definitions)
+}
+
+// Checksum returns the hex encoded SHA-256 of the file the store was loaded
+// from, or an empty string for stores created with NewFormulaStore
+func (s *FormulaStore) Checksum() string {
+	if s == nil {
+		return ""
+	}
+	return s.checksum
+}
+
+// Version returns the name of this formula set. Unless one was assigned with
+// WithVersion it is derived from the checksum, so different coefficients
+// always have different versions.
+func (s *FormulaStore) Version() string {
+	if s == nil {
+		return ""
+	}
+	if s.version != "" {
+		return s.version
+	}
+	if len(s.checksum) >= versionChecksumLength {
+		return s.checksum[:versionChecksumLength]
+	}
+	return s.checksum
+}
+
+// WithVersion returns a copy of the store named version
+func (s *FormulaStore) WithVersion(version string) *FormulaStore {
+	named := *s
+	named.version = version
+	return &named
+}
+
+// Formulas returns a copy of the CSV formulas in the store, or nil if it was
+// loaded from formula definitions
+func (s *FormulaStore) Formulas() []Formula {
+	if s == nil {
+		return nil
+	}
+	return append([]Formula(nil), s.formulas...)
+}
+
+// Definitions returns a copy of the formula definitions in the store
+func (s *FormulaStore) Definitions() []FormulaDefinition {
+	if s == nil {
+		return nil
+	}
+	return append([]FormulaDefinition(nil), s.definitions...)
+}
+
+// MaxRetrievals returns the highest number of intended retrievals any formula
+// in the store can estimate, 1 for an empty store
+func (s *FormulaStore) MaxRetrievals() int {
+	most := 1
+	for i := range s.definitions {
+		most = max(most, s.definitions[i].MaxRetrievals())
+	}
+	return most
+}
+
+// findDefinition returns the index of the first formula that applies to the
+// patient, or -1 if none does
+func (s *FormulaStore) findDefinition(in *patientInputs) (int, error) {
+	for i := range s.definitions {
+		applies, err := s.definitions[i].applies(in)
+		if err != nil {
+			return -1, err
+		}
+		if applies {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// findMatchingFormula returns the CSV formula that applies to a request, or
+// nil if none does or the store was not loaded from a CSV
+func (s *FormulaStore) findMatchingFormula(req CalculateRequest) *Formula {
+	i, err := s.findDefinition(&patientInputs{req: req})
+	if i < 0 || err != nil || i >= len(s.formulas) {
+		return nil
+	}
+	return &s.formulas[i]
+}
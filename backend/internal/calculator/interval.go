@@ -0,0 +1,191 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// DefaultConfidenceLevel is the level of confidence intervals unless the
+// Calculator or the request sets another
+const DefaultConfidenceLevel = 0.95
+
+// ConfidenceInterval bounds CumulativeChancePercent at Level. It is derived
+// from the covariance of the formula's coefficients with the delta method on
+// the logit scale. When the model has no variance data for the formula,
+// Available is false, the bounds are omitted and Reason says why.
+type ConfidenceInterval struct {
+	Level        float64  `json:"level"`
+	Available    bool     `json:"available"`
+	LowerPercent *float64 `json:"lowerPercent,omitempty"`
+	UpperPercent *float64 `json:"upperPercent,omitempty"`
+	Reason       string   `json:"reason,omitempty"`
+}
+
+// options holds the Calculator-wide defaults of settings a request can override
+type options struct {
+	mu              sync.RWMutex
+	confidenceLevel float64
+	rounding        string
+}
+
+// SetConfidenceLevel sets the level of confidence intervals for requests that
+// do not set confidenceLevel. It must be between 0 and 1 exclusive.
+func (c *Calculator) SetConfidenceLevel(level float64) error {
+	if !(level > 0 && level < 1) {
+		return fmt.Errorf("%w: confidence level must be between 0 and 1, got %v", ErrInvalidOption, level)
+	}
+	c.options.mu.Lock()
+	defer c.options.mu.Unlock()
+	c.options.confidenceLevel = level
+	return nil
+}
+
+// confidenceLevel returns the level of the request's confidence interval
+func (c *Calculator) confidenceLevel(req CalculateRequest) float64 {
+	if req.ConfidenceLevel != 0 {
+		return req.ConfidenceLevel
+	}
+	c.options.mu.RLock()
+	defer c.options.mu.RUnlock()
+	if c.options.confidenceLevel == 0 {
+		return DefaultConfidenceLevel
+	}
+	return c.options.confidenceLevel
+}
+
+// checkConfidenceLevel rejects levels a normal quantile cannot be computed for
+func checkConfidenceLevel(level float64) error {
+	if !(level > 0 && level < 1) {
+		return fmt.Errorf("%w: confidence level must be between 0 and 1, got %v", ErrOutOfDomain, level)
+	}
+	return nil
+}
+
+// confidenceInterval computes the interval of a prediction at level, with the
+// bounds rounded by policy. The logit ± z × standard error is converted back
+// to a probability, so the bounds stay within 0 and 100% and are asymmetric
+// around the estimate.
+func confidenceInterval(prediction Prediction, model ModelInfo, level float64, policy string) *ConfidenceInterval {
+	interval := &ConfidenceInterval{Level: level}
+	if prediction.LogitStandardError == nil {
+		if prediction.FormulaID != "" {
+			interval.Reason = fmt.Sprintf("no variance data for formula %s", prediction.FormulaID)
+		} else {
+			interval.Reason = fmt.Sprintf("model %s has no variance data", model.Name)
+		}
+		return interval
+	}
+
+	logit := math.Log(prediction.Probability / (1 - prediction.Probability))
+	if prediction.Breakdown != nil {
+		logit = prediction.Breakdown.Logit
+	}
+
+	// Two-sided quantile of the standard normal distribution
+	z := math.Sqrt2 * math.Erfinv(level)
+	margin := z * *prediction.LogitStandardError
+	lower := roundPercent(logistic(logit-margin), policy)
+	upper := roundPercent(logistic(logit+margin), policy)
+
+	interval.Available = true
+	interval.LowerPercent = &lower
+	interval.UpperPercent = &upper
+	return interval
+}
+
+// logistic converts a logit to a probability. Only a negative exponent is
+// taken, so large logits cannot overflow to Inf/Inf.
+func logistic(logit float64) float64 {
+	if logit >= 0 {
+		return 1.0 / (1.0 + math.Exp(-logit))
+	}
+	return math.Exp(logit) / (1.0 + math.Exp(logit))
+}
+
+// covariance returns the covariance of two coefficients of the formula, given
+// in either order. Coefficients missing from the matrix are taken as exact.
+func (d *FormulaDefinition) covariance(a, b string) float64 {
+	if v, ok := d.Covariance[a][b]; ok {
+		return v
+	}
+	return d.Covariance[b][a]
+}
+
+// logitStandardError is the standard error of the logit summed from terms, or
+// nil if the formula has no covariance. By the delta method the variance is
+// gᵀΣg, where the gradient g of the logit with respect to each coefficient is
+// the value the coefficient multiplies and Σ is the coefficient covariance.
+// Exponents of power terms are taken as exact.
+func (d *FormulaDefinition) logitStandardError(terms []Term) *float64 {
+	if len(d.Covariance) == 0 {
+		return nil
+	}
+	variance := 0.0
+	for _, a := range terms {
+		for _, b := range terms {
+			variance += a.Value * b.Value * d.covariance(a.Name, b.Name)
+		}
+	}
+	// Rounding can leave a tiny negative variance for a semidefinite matrix
+	se := math.Sqrt(math.Max(variance, 0))
+	return &se
+}
+
+// coefficientNames lists the coefficients of the formula as they are named in
+// a Breakdown and in the covariance matrix
+func (d *FormulaDefinition) coefficientNames() []string {
+	var names []string
+	for _, term := range d.Terms {
+		if term.Kind != TermCategorical {
+			names = append(names, term.Name)
+			continue
+		}
+		for _, level := range term.Levels {
+			names = append(names, term.Name+"_"+level.Value)
+		}
+	}
+	return names
+}
+
+// positiveSemidefinite reports whether the symmetric matrix m is a valid
+// covariance matrix, by attempting an LDLᵀ decomposition. Zero pivots are
+// allowed as long as the rest of their column is zero too, since the
+// reference levels of categorical terms have no variance.
+func positiveSemidefinite(m [][]float64) bool {
+	n := len(m)
+	scale := 0.0
+	for i := range m {
+		scale = math.Max(scale, math.Abs(m[i][i]))
+	}
+	tolerance := 1e-10 * scale
+
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+	}
+	d := make([]float64, n)
+	for j := 0; j < n; j++ {
+		d[j] = m[j][j]
+		for k := 0; k < j; k++ {
+			d[j] -= l[j][k] * l[j][k] * d[k]
+		}
+		if d[j] < -tolerance {
+			return false
+		}
+		for i := j + 1; i < n; i++ {
+			s := m[i][j]
+			for k := 0; k < j; k++ {
+				s -= l[i][k] * l[j][k] * d[k]
+			}
+			if d[j] <= tolerance {
+				if math.Abs(s) > tolerance {
+					return false
+				}
+				continue
+			}
+			l[i][j] = s / d[j]
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// covarianceStore returns the CDC formulas with variance data for the
+// intercept and age coefficients of every formula
+func covarianceStore(t *testing.T) *FormulaStore {
+	t.Helper()
+	definitions := testStore(t).Definitions()
+	for i := range definitions {
+		definitions[i].Covariance = map[string]map[string]float64{
+			"intercept":  {"intercept": 0.04, "age_linear": -0.001},
+			"age_linear": {"age_linear": 0.0001},
+		}
+	}
+	return NewDefinitionStore(definitions)
+}
+
+func TestCalculate_ConfidenceIntervalUnavailable(t *testing.T) {
+	result, err := testCalculator(t).Calculate(context.Background(), scenario1Request())
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+
+	interval := result.ConfidenceInterval
+	if interval == nil || interval.Available || interval.LowerPercent != nil || interval.UpperPercent != nil {
+		t.Fatalf("Expected an unavailable interval without bounds, got %+v", interval)
+	}
+	if interval.Level != DefaultConfidenceLevel || interval.Reason != "no variance data for formula 1-3" {
+		t.Errorf("Unexpected interval %+v", interval)
+	}
+}
+
+func TestCalculate_ConfidenceInterval(t *testing.T) {
+	calc := New(covarianceStore(t))
+	req := scenario1Request()
+
+	tests := []struct {
+		name  string
+		level float64 // of the request, 0 for the calculator's
+		z     float64
+	}{
+		{name: "default level", z: 1.959963984540054},
+		{name: "requested level", level: 0.8, z: 1.2815515655446004},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req.ConfidenceLevel = tt.level
+			result, err := calc.Calculate(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Calculate returned error: %v", err)
+			}
+
+			// Only the intercept and age_linear have variance; their gradients
+			// are 1 and the age
+			age := float64(req.Age)
+			se := math.Sqrt(0.04 + 2*age*-0.001 + age*age*0.0001)
+			if got := *result.Breakdown.LogitStandardError; math.Abs(got-se) > 1e-12 {
+				t.Errorf("Expected logit standard error %v, got %v", se, got)
+			}
+
+			logit := result.Breakdown.Logit
+			interval := result.ConfidenceInterval
+			if !interval.Available || interval.Reason != "" {
+				t.Fatalf("Expected an available interval, got %+v", interval)
+			}
+			if lower := toPercent(logistic(logit - tt.z*se)); *interval.LowerPercent != lower {
+				t.Errorf("Expected lower bound %v, got %v", lower, *interval.LowerPercent)
+			}
+			if upper := toPercent(logistic(logit + tt.z*se)); *interval.UpperPercent != upper {
+				t.Errorf("Expected upper bound %v, got %v", upper, *interval.UpperPercent)
+			}
+			if !(*interval.LowerPercent < result.CumulativeChancePercent && result.CumulativeChancePercent < *interval.UpperPercent) {
+				t.Errorf("Expected %v within the interval %+v", result.CumulativeChancePercent, interval)
+			}
+		})
+	}
+}
+
+func TestSetConfidenceLevel(t *testing.T) {
+	calc := New(covarianceStore(t))
+	narrow, err := calc.Calculate(context.Background(), scenario1Request())
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+
+	if err := calc.SetConfidenceLevel(0.99); err != nil {
+		t.Fatalf("SetConfidenceLevel returned error: %v", err)
+	}
+	wide, err := calc.Calculate(context.Background(), scenario1Request())
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if wide.ConfidenceInterval.Level != 0.99 ||
+		*wide.ConfidenceInterval.LowerPercent >= *narrow.ConfidenceInterval.LowerPercent ||
+		*wide.ConfidenceInterval.UpperPercent <= *narrow.ConfidenceInterval.UpperPercent {
+		t.Errorf("Expected a wider 99%% interval than %+v, got %+v", narrow.ConfidenceInterval, wide.ConfidenceInterval)
+	}
+
+	for _, level := range []float64{0, 1, -0.5, math.NaN()} {
+		if err := calc.SetConfidenceLevel(level); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Expected ErrInvalidOption for level %v, got %v", level, err)
+		}
+	}
+}
+
+func TestLoadFormulaDefinitions_Covariance(t *testing.T) {
+	const formula = `{"formulas": [{"id": "1", "when": {}, "terms": [
+		{"name": "intercept", "kind": "intercept", "coefficient": -1},
+		{"name": "age_linear", "kind": "linear", "input": "age", "coefficient": 0.1},
+		{"name": "tubal_factor", "kind": "categorical", "input": "reasons.tubal_factor",
+		 "levels": [{"value": "true", "coefficient": 0.2}, {"value": "false", "coefficient": 0}]}
+	], "covariance": %s}]}`
+
+	tests := []struct {
+		name       string
+		covariance string
+		want       string
+	}{
+		{
+			name:       "valid with a zero variance reference level",
+			covariance: `{"intercept": {"intercept": 0.04, "tubal_factor_true": 0.001}, "tubal_factor_true": {"tubal_factor_true": 0.01}}`,
+		},
+		{
+			name:       "unknown coefficient",
+			covariance: `{"intercept": {"intercept": 0.04, "bmi_linear": 0.001}}`,
+			want:       `formulas[0].covariance: unknown coefficient "bmi_linear"`,
+		},
+		{
+			name:       "negative variance",
+			covariance: `{"age_linear": {"age_linear": -0.01}}`,
+			want:       "formulas[0].covariance: variance of age_linear must not be negative, got -0.01",
+		},
+		{
+			name:       "asymmetric",
+			covariance: `{"intercept": {"intercept": 0.04, "age_linear": 0.001}, "age_linear": {"age_linear": 0.01, "intercept": 0.002}}`,
+			want:       "formulas[0].covariance: age_linear, intercept is 0.002 but intercept, age_linear is 0.001",
+		},
+		{
+			name:       "not positive semidefinite",
+			covariance: `{"intercept": {"intercept": 0.01, "age_linear": 0.5}, "age_linear": {"age_linear": 0.01}}`,
+			want:       "formulas[0].covariance: matrix is not positive semidefinite",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFormulaDefinitions(strings.NewReader(strings.Replace(formula, "%s", tt.covariance, 1)))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected a problem containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
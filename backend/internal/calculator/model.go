@@ -0,0 +1,223 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Patient is the input to a Model: a calculate request with BMI resolved from
+// whichever unit system it used
+type Patient struct {
+	Age              int
+	BMI              float64
+	EggSource        string
+	PriorIvfCycles   string
+	PriorPregnancies int
+	PriorBirths      int
+	Reasons          []string
+	Retrievals       int // intended retrievals, at least 1
+}
+
+// NewPatient resolves the patient described by a request
+func NewPatient(req CalculateRequest) (Patient, error) {
+	bmi, err := bodyMassIndex(req)
+	if err != nil {
+		return Patient{}, err
+	}
+	return Patient{
+		Age:              req.Age,
+		BMI:              bmi,
+		EggSource:        req.EggSource,
+		PriorIvfCycles:   req.PriorIvfCycles,
+		PriorPregnancies: req.PriorPregnancies,
+		PriorBirths:      req.PriorBirths,
+		Reasons:          req.Reasons,
+		Retrievals:       req.retrievalCount(),
+	}, nil
+}
+
+// request returns a request describing the patient with BMI given directly
+func (p Patient) request() CalculateRequest {
+	return CalculateRequest{
+		Age:              p.Age,
+		BMI:              p.BMI,
+		EggSource:        p.EggSource,
+		PriorIvfCycles:   p.PriorIvfCycles,
+		PriorPregnancies: p.PriorPregnancies,
+		PriorBirths:      p.PriorBirths,
+		Reasons:          p.Reasons,
+		Retrievals:       p.Retrievals,
+	}
+}
+
+// Prediction is a Model's estimate of the chance of a live birth
+type Prediction struct {
+	// Probability is the chance after the patient's intended retrievals
+	Probability float64
+	// ByRetrieval holds the chance after 1, 2, ... retrievals, for models that
+	// estimate several. Nil means only Probability is known.
+	ByRetrieval []float64
+	// FormulaID identifies the formula of the model that was applied, if any
+	FormulaID string
+	// Breakdown explains the prediction, for models with additive logit terms
+	Breakdown *Breakdown
+	// LogitStandardError is the standard error of the logit of Probability,
+	// or nil if the model has no variance data for the patient
+	LogitStandardError *float64
+}
+
+// ModelInfo describes a Model
+type ModelInfo struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Checksum    string   `json:"checksum,omitempty"`
+	Description string   `json:"description"`
+	Covariates  []string `json:"covariates"`
+	Default     bool     `json:"default"`
+}
+
+// Model predicts the chance of a live birth for a patient. Implementations
+// must be safe for concurrent use.
+type Model interface {
+	Info() ModelInfo
+	Predict(ctx context.Context, patient Patient) (Prediction, error)
+}
+
+// models holds the Models a Calculator can select besides the CDC formulas
+type models struct {
+	mu          sync.RWMutex
+	byName      map[string]Model
+	defaultName string
+}
+
+// RegisterModel makes model selectable by its name through
+// CalculateRequest.Model. The CDC formulas are always registered as "cdc".
+func (c *Calculator) RegisterModel(model Model) error {
+	name := model.Info().Name
+	if name == "" || name == CDCModelName {
+		return fmt.Errorf("invalid model name %q", name)
+	}
+
+	c.models.mu.Lock()
+	defer c.models.mu.Unlock()
+	if _, ok := c.models.byName[name]; ok {
+		return fmt.Errorf("model %q is already registered", name)
+	}
+	if c.models.byName == nil {
+		c.models.byName = make(map[string]Model)
+	}
+	c.models.byName[name] = model
+	return nil
+}
+
+// SetDefaultModel selects the model used by requests that do not name one
+func (c *Calculator) SetDefaultModel(name string) error {
+	c.models.mu.Lock()
+	defer c.models.mu.Unlock()
+	if _, ok := c.models.byName[name]; !ok && name != CDCModelName {
+		return fmt.Errorf("%w: %q", ErrUnknownModel, name)
+	}
+	c.models.defaultName = name
+	return nil
+}
+
+// Models lists the selectable models sorted by name. The CDC model is
+// described by its latest formulas.
+func (c *Calculator) Models() []ModelInfo {
+	c.models.mu.RLock()
+	defer c.models.mu.RUnlock()
+
+	infos := []ModelInfo{NewCDCModel(c.Store()).Info()}
+	for _, model := range c.models.byName {
+		infos = append(infos, model.Info())
+	}
+	for i := range infos {
+		infos[i].Default = infos[i].Name == c.defaultModelName()
+	}
+	slices.SortFunc(infos, func(a, b ModelInfo) int { return strings.Compare(a.Name, b.Name) })
+	return infos
+}
+
+// defaultModelName returns the model of requests that do not name one. The
+// caller must hold c.models.mu.
+func (c *Calculator) defaultModelName() string {
+	if c.models.defaultName == "" {
+		return CDCModelName
+	}
+	return c.models.defaultName
+}
+
+// model returns the model a request selects. The CDC model uses the formulas
+// of the request's model version; other models only accept their own version.
+func (c *Calculator) model(req CalculateRequest) (Model, error) {
+	c.models.mu.RLock()
+	name := req.Model
+	if name == "" {
+		name = c.defaultModelName()
+	}
+	model, ok := c.models.byName[name]
+	c.models.mu.RUnlock()
+
+	if name == CDCModelName {
+		store, err := c.sets.Load().lookup(req.ModelVersion)
+		if err != nil {
+			return nil, err
+		}
+		if store.Len() == 0 {
+			return nil, ErrNoFormulas
+		}
+		return NewCDCModel(store), nil
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownModel, name)
+	}
+	if version := model.Info().Version; req.ModelVersion != "" && req.ModelVersion != version {
+		return nil, fmt.Errorf("%w: %q of model %s", ErrUnknownModelVersion, req.ModelVersion, name)
+	}
+	return model, nil
+}
+
+// predict calculates a request with model
+func (c *Calculator) predict(ctx context.Context, model Model, req CalculateRequest) (CalculateResponse, error) {
+	level := c.confidenceLevel(req)
+	if err := checkConfidenceLevel(level); err != nil {
+		return CalculateResponse{}, err
+	}
+	rounding := c.rounding(req)
+	if err := checkRounding(rounding); err != nil {
+		return CalculateResponse{}, err
+	}
+	patient, err := NewPatient(req)
+	if err != nil {
+		return CalculateResponse{}, err
+	}
+	prediction, err := model.Predict(ctx, patient)
+	if err != nil {
+		return CalculateResponse{}, err
+	}
+
+	var chances []RetrievalChance
+	for i, probability := range prediction.ByRetrieval {
+		chances = append(chances, RetrievalChance{Retrievals: i + 1, CumulativeChancePercent: roundPercent(probability, rounding), Probability: probability})
+	}
+	if chances == nil {
+		chances = []RetrievalChance{{Retrievals: patient.Retrievals, CumulativeChancePercent: roundPercent(prediction.Probability, rounding), Probability: prediction.Probability}}
+	}
+
+	info := model.Info()
+	return CalculateResponse{
+		CumulativeChancePercent: roundPercent(prediction.Probability, rounding),
+		Probability:             prediction.Probability,
+		Rounding:                rounding,
+		ChancesByRetrieval:      chances,
+		FormulaID:               prediction.FormulaID,
+		Model:                   info.Name,
+		ModelVersion:            info.Version,
+		FormulaChecksum:         info.Checksum,
+		ConfidenceInterval:      confidenceInterval(prediction, info, level, rounding),
+		Breakdown:               prediction.Breakdown,
+	}, nil
+}
@@ -0,0 +1,136 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fixedModel predicts the same chance for every patient
+type fixedModel struct {
+	name        string
+	probability float64
+}
+
+func (m fixedModel) Info() ModelInfo {
+	return ModelInfo{Name: m.name, Version: "1", Description: "fixed chance", Covariates: []string{}}
+}
+
+func (m fixedModel) Predict(ctx context.Context, patient Patient) (Prediction, error) {
+	return Prediction{Probability: m.probability}, nil
+}
+
+func TestCalculate_SelectsModel(t *testing.T) {
+	calc := testCalculator(t)
+	if err := calc.RegisterModel(fixedModel{name: "clinic", probability: 0.41234}); err != nil {
+		t.Fatalf("RegisterModel returned error: %v", err)
+	}
+
+	req := CalculateRequest{
+		Age:              32,
+		BMI:              22.8,
+		PriorIvfCycles:   "no",
+		PriorPregnancies: 1,
+		PriorBirths:      1,
+		Reasons:          []string{"endometriosis", "ovulatory_disorder"},
+		EggSource:        "own",
+	}
+
+	tests := []struct {
+		name         string
+		defaultModel string
+		model        string
+		version      string
+		wantModel    string
+		wantChance   float64
+		wantErr      error
+	}{
+		{name: "CDC by default", wantModel: CDCModelName, wantChance: 62.21},
+		{name: "per request", model: "clinic", wantModel: "clinic", wantChance: 41.24},
+		{name: "by configuration", defaultModel: "clinic", wantModel: "clinic", wantChance: 41.24},
+		{name: "request overrides configuration", defaultModel: "clinic", model: CDCModelName, wantModel: CDCModelName, wantChance: 62.21},
+		{name: "own version", model: "clinic", version: "1", wantModel: "clinic", wantChance: 41.24},
+		{name: "unknown version", model: "clinic", version: "2", wantErr: ErrUnknownModelVersion},
+		{name: "unknown model", model: "sart", wantErr: ErrUnknownModel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultModel := tt.defaultModel
+			if defaultModel == "" {
+				defaultModel = CDCModelName
+			}
+			if err := calc.SetDefaultModel(defaultModel); err != nil {
+				t.Fatalf("SetDefaultModel returned error: %v", err)
+			}
+			req := req
+			req.Model = tt.model
+			req.ModelVersion = tt.version
+
+			result, err := calc.Calculate(context.Background(), req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if result.Model != tt.wantModel || result.CumulativeChancePercent != tt.wantChance {
+				t.Errorf("Expected %v from %s, got %v from %s", tt.wantChance, tt.wantModel, result.CumulativeChancePercent, result.Model)
+			}
+		})
+	}
+}
+
+func TestCalculate_ModelWithoutRetrievalEstimates(t *testing.T) {
+	calc := testCalculator(t)
+	if err := calc.RegisterModel(fixedModel{name: "clinic", probability: 0.5}); err != nil {
+		t.Fatalf("RegisterModel returned error: %v", err)
+	}
+
+	result, err := calc.Calculate(context.Background(), CalculateRequest{
+		Age: 32, BMI: 22.8, EggSource: "donor", Reasons: []string{"other"}, Retrievals: 3, Model: "clinic",
+	})
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if len(result.ChancesByRetrieval) != 1 || result.ChancesByRetrieval[0] != (RetrievalChance{Retrievals: 3, CumulativeChancePercent: 50, Probability: 0.5}) {
+		t.Errorf("Expected only the chance after 3 retrievals, got %+v", result.ChancesByRetrieval)
+	}
+	if result.Breakdown != nil || result.FormulaChecksum != "" {
+		t.Errorf("Expected no CDC details, got %+v", result)
+	}
+}
+
+func TestRegisterModel(t *testing.T) {
+	calc := testCalculator(t)
+
+	if err := calc.RegisterModel(fixedModel{name: CDCModelName}); err == nil {
+		t.Error("Expected an error registering over the CDC model")
+	}
+	if err := calc.RegisterModel(fixedModel{name: ""}); err == nil {
+		t.Error("Expected an error registering a model without a name")
+	}
+	if err := calc.RegisterModel(fixedModel{name: "clinic"}); err != nil {
+		t.Fatalf("RegisterModel returned error: %v", err)
+	}
+	if err := calc.RegisterModel(fixedModel{name: "clinic"}); err == nil {
+		t.Error("Expected an error registering a name twice")
+	}
+	if err := calc.SetDefaultModel("sart"); !errors.Is(err, ErrUnknownModel) {
+		t.Errorf("Expected ErrUnknownModel, got %v", err)
+	}
+	if err := calc.SetDefaultModel("clinic"); err != nil {
+		t.Fatalf("SetDefaultModel returned error: %v", err)
+	}
+
+	models := calc.Models()
+	if len(models) != 2 || models[0].Name != CDCModelName || models[1].Name != "clinic" {
+		t.Fatalf("Expected the cdc and clinic models, got %+v", models)
+	}
+	if models[0].Default || !models[1].Default {
+		t.Errorf("Expected clinic to be the default, got %+v", models)
+	}
+	if models[0].Version != calc.Store().Version() || models[0].Checksum != calc.Store().Checksum() {
+		t.Errorf("Expected the CDC model to describe the latest formulas, got %+v", models[0])
+	}
+}
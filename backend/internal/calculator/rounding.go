@@ -0,0 +1,72 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Rounding policies of the percentages in responses. The probability they are
+// rounded from is always returned unrounded alongside.
+const (
+	RoundCeil     = "ceil"      // up to 2 decimal places, as the CDC estimator does
+	RoundHalfEven = "half_even" // to the nearest 2 decimal places, halves to even
+	RoundFloor    = "floor"     // down to 2 decimal places
+	RoundInteger  = "integer"   // to the nearest whole percent, halves to even
+	RoundRaw      = "raw"       // not rounded
+)
+
+// RoundingPolicies lists the accepted rounding policies
+var RoundingPolicies = []string{RoundCeil, RoundHalfEven, RoundFloor, RoundInteger, RoundRaw}
+
+// DefaultRounding is the rounding policy unless the Calculator or the request
+// sets another
+const DefaultRounding = RoundCeil
+
+// SetRounding sets the rounding policy for requests that do not set rounding
+func (c *Calculator) SetRounding(policy string) error {
+	if !slices.Contains(RoundingPolicies, policy) {
+		return fmt.Errorf("%w: rounding must be one of %v, got %q", ErrInvalidOption, RoundingPolicies, policy)
+	}
+	c.options.mu.Lock()
+	defer c.options.mu.Unlock()
+	c.options.rounding = policy
+	return nil
+}
+
+// rounding returns the rounding policy of the request
+func (c *Calculator) rounding(req CalculateRequest) string {
+	if req.Rounding != "" {
+		return req.Rounding
+	}
+	c.options.mu.RLock()
+	defer c.options.mu.RUnlock()
+	if c.options.rounding == "" {
+		return DefaultRounding
+	}
+	return c.options.rounding
+}
+
+// checkRounding rejects unknown rounding policies in requests
+func checkRounding(policy string) error {
+	if !slices.Contains(RoundingPolicies, policy) {
+		return fmt.Errorf("%w: rounding must be one of %v, got %q", ErrOutOfDomain, RoundingPolicies, policy)
+	}
+	return nil
+}
+
+// roundPercent converts a probability to a percentage rounded by policy
+func roundPercent(probability float64, policy string) float64 {
+	switch policy {
+	case RoundHalfEven:
+		return math.RoundToEven(probability*10000.0) / 100.0
+	case RoundFloor:
+		return math.Floor(probability*10000.0) / 100.0
+	case RoundInteger:
+		return math.RoundToEven(probability * 100.0)
+	case RoundRaw:
+		return probability * 100.0
+	default:
+		return toPercent(probability)
+	}
+}
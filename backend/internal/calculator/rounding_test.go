@@ -0,0 +1,141 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// baselineScenarios returns the six patients of the TestCalculate_*_Scenario
+// tests, in order
+func baselineScenarios() []CalculateRequest {
+	weightLbs, heightFt, heightIn := getWeightHeightForBMI(22.8)
+	base := CalculateRequest{Age: 32, WeightLbs: weightLbs, HeightFt: heightFt, HeightIn: heightIn}
+
+	scenario := func(eggSource, priorIVF string, pregnancies, births int, reasons ...string) CalculateRequest {
+		req := base
+		req.EggSource, req.PriorIvfCycles, req.PriorPregnancies, req.PriorBirths, req.Reasons = eggSource, priorIVF, pregnancies, births, reasons
+		return req
+	}
+	return []CalculateRequest{
+		scenario("own", "no", 1, 1, "endometriosis", "ovulatory_disorder"),
+		scenario("own", "no", 1, 1, "unknown"),
+		scenario("own", "yes", 1, 1, "tubal_factor", "diminished_ovarian_reserve"),
+		scenario("own", "yes", 1, 1, "unknown"),
+		scenario("donor", "", 2, 1, "uterine_factor"),
+		scenario("donor", "", 0, 0, "unknown"),
+	}
+}
+
+func TestCalculate_Rounding(t *testing.T) {
+	// Expected percentages of baselineScenarios, in order, by policy; ceil
+	// gives the baseline results
+	tests := []struct {
+		policy string
+		want   []float64
+	}{
+		{policy: RoundCeil, want: []float64{62.21, 59.83, 40.89, 53.82, 55.43, 56.8}},
+		{policy: RoundHalfEven, want: []float64{62.2, 59.82, 40.89, 53.82, 55.43, 56.8}},
+		{policy: RoundFloor, want: []float64{62.2, 59.82, 40.88, 53.81, 55.42, 56.79}},
+		{policy: RoundInteger, want: []float64{62, 60, 41, 54, 55, 57}},
+		{policy: RoundRaw, want: []float64{62.203762076568815, 59.822077332204785, 40.88799249775874, 53.81774780999059, 55.429725840765876, 56.79717388701482}},
+	}
+	probabilities := []float64{0.62203762076568814, 0.59822077332204782, 0.40887992497758741, 0.5381774780999059, 0.55429725840765875, 0.56797173887014818}
+
+	calc := testCalculator(t)
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			for i, req := range baselineScenarios() {
+				req.Rounding = tt.policy
+				result, err := calc.Calculate(context.Background(), req)
+				if err != nil {
+					t.Fatalf("Calculate returned error: %v", err)
+				}
+				if result.CumulativeChancePercent != tt.want[i] || result.ChancesByRetrieval[0].CumulativeChancePercent != tt.want[i] {
+					t.Errorf("Scenario %d: expected %v, got %v and %+v", i+1, tt.want[i], result.CumulativeChancePercent, result.ChancesByRetrieval)
+				}
+				// The unrounded probability does not depend on the policy
+				if result.Probability != probabilities[i] || result.ChancesByRetrieval[0].Probability != probabilities[i] {
+					t.Errorf("Scenario %d: expected probability %v, got %v", i+1, probabilities[i], result.Probability)
+				}
+				if result.Rounding != tt.policy {
+					t.Errorf("Expected rounding %q, got %q", tt.policy, result.Rounding)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundPercent_Halves(t *testing.T) {
+	// 0.375 and 0.625 are exact in binary, so these are true halves
+	tests := []struct {
+		probability float64
+		policy      string
+		want        float64
+	}{
+		{0.375, RoundInteger, 38},
+		{0.625, RoundInteger, 62},
+		{0.375, RoundHalfEven, 37.5},
+		{0.62201, RoundCeil, 62.21},
+		{0.62209, RoundFloor, 62.2},
+	}
+
+	for _, tt := range tests {
+		if got := roundPercent(tt.probability, tt.policy); got != tt.want {
+			t.Errorf("roundPercent(%v, %q) = %v, want %v", tt.probability, tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestSetRounding(t *testing.T) {
+	calc := New(covarianceStore(t))
+	if err := calc.SetRounding(RoundInteger); err != nil {
+		t.Fatalf("SetRounding returned error: %v", err)
+	}
+
+	result, err := calc.Calculate(context.Background(), scenario1Request())
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	interval := result.ConfidenceInterval
+	if result.Rounding != RoundInteger || result.CumulativeChancePercent != 62 ||
+		*interval.LowerPercent != 49 || *interval.UpperPercent != 74 {
+		t.Errorf("Expected whole percentages, got %v with interval %+v", result.CumulativeChancePercent, interval)
+	}
+
+	// The request overrides the calculator's policy
+	req := scenario1Request()
+	req.Rounding = RoundCeil
+	result, err = calc.Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if result.Rounding != RoundCeil || result.CumulativeChancePercent != 62.21 {
+		t.Errorf("Expected 62.21 rounded up, got %v by %q", result.CumulativeChancePercent, result.Rounding)
+	}
+
+	if err := calc.SetRounding("nearest"); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+	req.Rounding = "nearest"
+	if _, err := calc.Calculate(context.Background(), req); !errors.Is(err, ErrOutOfDomain) {
+		t.Errorf("Expected ErrOutOfDomain, got %v", err)
+	}
+}
+
+func TestCurve_Rounding(t *testing.T) {
+	base := scenario1Request()
+	base.Rounding = RoundRaw
+	result, err := testCalculator(t).Curve(context.Background(), CurveRequest{Base: base, Dimension: CurveAge, From: 30, To: 34, Step: 2})
+	if err != nil {
+		t.Fatalf("Curve returned error: %v", err)
+	}
+	if result.Rounding != RoundRaw {
+		t.Errorf("Expected rounding %q, got %q", RoundRaw, result.Rounding)
+	}
+	for _, point := range result.Points {
+		if point.CumulativeChancePercent != point.Probability*100 {
+			t.Errorf("Expected the unrounded chance at age %v, got %v", point.Value, point.CumulativeChancePercent)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// Limits and defaults of a simulation
+const (
+	MaxSimulationDraws       = 1_000_000
+	DefaultSimulationBuckets = 20
+	MaxSimulationBuckets     = 100
+	// MaxStandardError bounds the standard error of a coefficient; larger
+	// ones only push every draw to 0% or 100%
+	MaxStandardError = 10
+)
+
+// DefaultSimulationPercentiles are reported unless the request lists others
+var DefaultSimulationPercentiles = []float64{2.5, 5, 25, 50, 75, 95, 97.5}
+
+// simulationChunk is how many draws share a random stream. Each chunk is
+// seeded from the request seed and its index, so the draws are the same
+// however the chunks are spread over goroutines.
+const simulationChunk = 4096
+
+// InputNoise holds the standard deviations of measurement error added to the
+// base patient in every draw, in the unit system of the base request
+type InputNoise struct {
+	Weight float64 `json:"weight,omitempty"` // lbs or kg
+	Height float64 `json:"height,omitempty"` // inches or cm
+	BMI    float64 `json:"bmi,omitempty"`    // for requests giving bmi directly
+}
+
+// SimulationRequest represents the request body for the simulate endpoint: a
+// base patient evaluated Draws times with perturbed coefficients and inputs
+type SimulationRequest struct {
+	Base  CalculateRequest `json:"base" binding:"required"`
+	Draws int              `json:"draws" binding:"required"`
+	Seed  uint64           `json:"seed"`
+	// StandardErrors of coefficients, named as in a Breakdown. When empty the
+	// variances of the formula's covariance are used.
+	StandardErrors map[string]float64 `json:"standardErrors,omitempty"`
+	InputNoise     InputNoise         `json:"inputNoise"`
+	Percentiles    []float64          `json:"percentiles,omitempty"`
+	Buckets        int                `json:"buckets,omitempty"`
+}
+
+// SimulationPercentile is a percentile of the simulated chances
+type SimulationPercentile struct {
+	Percentile              float64 `json:"percentile"`
+	CumulativeChancePercent float64 `json:"cumulativeChancePercent"`
+}
+
+// HistogramBucket counts the draws from FromPercent up to ToPercent. The last
+// bucket includes ToPercent.
+type HistogramBucket struct {
+	FromPercent float64 `json:"fromPercent"`
+	ToPercent   float64 `json:"toPercent"`
+	Count       int     `json:"count"`
+}
+
+// SimulationResponse represents the response from the simulate endpoint
+type SimulationResponse struct {
+	Draws int    `json:"draws"`
+	Seed  uint64 `json:"seed"`
+	// CumulativeChancePercent is the estimate without perturbation
+	CumulativeChancePercent float64                `json:"cumulativeChancePercent"`
+	Mean                    float64                `json:"mean"`
+	StandardDeviation       float64                `json:"standardDeviation"`
+	Rounding                string                 `json:"rounding"`
+	Percentiles             []SimulationPercentile `json:"percentiles"`
+	Histogram               []HistogramBucket      `json:"histogram"`
+	StandardErrors          map[string]float64     `json:"standardErrors"`
+	FormulaID               string                 `json:"formulaId"`
+	Model                   string                 `json:"model"`
+	ModelVersion            string                 `json:"modelVersion"`
+	FormulaChecksum         string                 `json:"formulaChecksum,omitempty"`
+}
+
+// Simulate runs a Monte Carlo simulation of the chance of success. Every draw
+// adds normal noise with the given standard errors to each coefficient of the
+// formula, independently, and to the body measurements of the base patient,
+// then evaluates the formula. A simulation without standard errors, from the
+// request or the formula's covariance, or input noise is ErrOutOfDomain.
+// Draws are split into chunks computed in parallel; the result only depends
+// on the request, including its seed. The draws are kept unrounded, and the
+// rounding policy only applies to the reported mean and percentiles.
+func (c *Calculator) Simulate(ctx context.Context, req SimulationRequest) (SimulationResponse, error) {
+	if req.Draws < 1 || req.Draws > MaxSimulationDraws {
+		return SimulationResponse{}, fmt.Errorf("%w: draws must be between 1 and %d, got %d", ErrOutOfDomain, MaxSimulationDraws, req.Draws)
+	}
+
+	model, err := c.model(req.Base)
+	if err != nil {
+		return SimulationResponse{}, err
+	}
+	cdc, ok := model.(*CDCModel)
+	if !ok {
+		return SimulationResponse{}, fmt.Errorf("%w: model %s has no formula coefficients to simulate", ErrOutOfDomain, model.Info().Name)
+	}
+
+	rounding := c.rounding(req.Base)
+	if err := checkRounding(rounding); err != nil {
+		return SimulationResponse{}, err
+	}
+	sim, err := newSimulation(cdc.store, req)
+	if err != nil {
+		return SimulationResponse{}, err
+	}
+
+	probabilities := make([]float64, req.Draws)
+	chunks := (req.Draws + simulationChunk - 1) / simulationChunk
+	errs := make([]error, chunks)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range indexes {
+				start := chunk * simulationChunk
+				end := min(start+simulationChunk, req.Draws)
+				errs[chunk] = sim.run(req.Seed, chunk, probabilities[start:end])
+			}
+		}()
+	}
+
+	for chunk := 0; chunk < chunks; chunk++ {
+		select {
+		case indexes <- chunk:
+		case <-ctx.Done():
+			errs[chunk] = ctx.Err()
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for chunk, err := range errs {
+		if err != nil {
+			return SimulationResponse{}, fmt.Errorf("draws %d to %d: %w", chunk*simulationChunk+1, min((chunk+1)*simulationChunk, req.Draws), err)
+		}
+	}
+
+	info := model.Info()
+	response := SimulationResponse{
+		Draws:                   req.Draws,
+		Seed:                    req.Seed,
+		CumulativeChancePercent: roundPercent(sim.base.Probability, rounding),
+		Rounding:                rounding,
+		StandardErrors:          sim.standardErrors,
+		FormulaID:               sim.base.FormulaID,
+		Model:                   info.Name,
+		ModelVersion:            info.Version,
+		FormulaChecksum:         info.Checksum,
+	}
+	// The policy rounds the chances; the deviation and bucket bounds are not
+	// chances, and rounding them up or to whole percents would inflate the
+	// spread and collapse narrow buckets
+	mean, deviation := meanAndDeviation(probabilities)
+	response.Mean, response.StandardDeviation = roundPercent(mean, rounding), roundPercent(deviation, RoundHalfEven)
+
+	slices.Sort(probabilities)
+	percentiles := req.Percentiles
+	if len(percentiles) == 0 {
+		percentiles = DefaultSimulationPercentiles
+	}
+	for _, p := range percentiles {
+		response.Percentiles = append(response.Percentiles, SimulationPercentile{Percentile: p, CumulativeChancePercent: roundPercent(percentile(probabilities, p), rounding)})
+	}
+	buckets := req.Buckets
+	if buckets == 0 {
+		buckets = DefaultSimulationBuckets
+	}
+	response.Histogram = histogram(probabilities, buckets)
+	for i := range response.Histogram {
+		bucket := &response.Histogram[i]
+		bucket.FromPercent, bucket.ToPercent = roundPercent(bucket.FromPercent, RoundHalfEven), roundPercent(bucket.ToPercent, RoundHalfEven)
+	}
+	return response, nil
+}
+
+// simulation holds what every draw of a simulation shares
+type simulation struct {
+	store          *FormulaStore
+	req            CalculateRequest
+	noise          InputNoise
+	base           *Breakdown
+	standardErrors map[string]float64
+}
+
+// newSimulation evaluates the base patient and resolves the standard errors
+// of its formula's coefficients
+func newSimulation(store *FormulaStore, req SimulationRequest) (*simulation, error) {
+	base, err := explain(store, req.Base)
+	if err != nil {
+		return nil, err
+	}
+	formula := &store.definitions[slices.IndexFunc(store.definitions, func(d FormulaDefinition) bool { return d.ID == base.FormulaID })]
+
+	if err := checkInputNoise(req.Base, req.InputNoise); err != nil {
+		return nil, err
+	}
+
+	standardErrors := make(map[string]float64)
+	names := formula.coefficientNames()
+	for name, se := range req.StandardErrors {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("%w: formula %s has no coefficient %q", ErrOutOfDomain, formula.ID, name)
+		}
+		if !(se >= 0 && se <= MaxStandardError) {
+			return nil, fmt.Errorf("%w: standard error of %s must be between 0 and %d, got %v", ErrOutOfDomain, name, MaxStandardError, se)
+		}
+		standardErrors[name] = se
+	}
+	if len(req.StandardErrors) == 0 {
+		for _, name := range names {
+			if variance := formula.covariance(name, name); variance > 0 {
+				standardErrors[name] = math.Sqrt(variance)
+			}
+		}
+	}
+
+	// Without any uncertainty every draw would be the point estimate
+	uncertain := req.InputNoise != InputNoise{}
+	for _, se := range standardErrors {
+		uncertain = uncertain || se > 0
+	}
+	if !uncertain {
+		return nil, fmt.Errorf("%w: formula %s has no variance data, so standardErrors or inputNoise are needed to simulate", ErrOutOfDomain, formula.ID)
+	}
+
+	return &simulation{store: store, req: req.Base, noise: req.InputNoise, base: base, standardErrors: standardErrors}, nil
+}
+
+// checkInputNoise rejects noise that is negative or for measurements the
+// request does not give
+func checkInputNoise(req CalculateRequest, noise InputNoise) error {
+	for _, sd := range []float64{noise.Weight, noise.Height, noise.BMI} {
+		if !(sd >= 0) || math.IsInf(sd, 0) {
+			return fmt.Errorf("%w: input noise must not be negative, got %v", ErrOutOfDomain, sd)
+		}
+	}
+	systems := req.UnitSystems()
+	if len(systems) != 1 {
+		return fmt.Errorf("%w: expected exactly one unit system, got %v", ErrOutOfDomain, systems)
+	}
+	if systems[0] == UnitsBMI && (noise.Weight != 0 || noise.Height != 0) {
+		return fmt.Errorf("%w: weight and height noise need a request with weight and height", ErrOutOfDomain)
+	}
+	if systems[0] != UnitsBMI && noise.BMI != 0 {
+		return fmt.Errorf("%w: bmi noise needs a request with bmi", ErrOutOfDomain)
+	}
+	return nil
+}
+
+// run computes the draws of one chunk into probabilities
+func (s *simulation) run(seed uint64, chunk int, probabilities []float64) error {
+	rng := rand.New(rand.NewPCG(seed, uint64(chunk)))
+	noisy := s.noise != InputNoise{}
+
+	for i := range probabilities {
+		breakdown := s.base
+		if noisy {
+			bmi, err := s.bmi(rng)
+			if err != nil {
+				return err
+			}
+			req := s.req
+			req.WeightLbs, req.HeightFt, req.HeightIn, req.WeightKg, req.HeightCm = 0, 0, 0, 0, 0
+			req.BMI = bmi
+			if breakdown, err = explain(s.store, req); err != nil {
+				return err
+			}
+		}
+
+		// One normal draw per term keeps the random stream aligned across draws
+		logit := 0.0
+		for _, term := range breakdown.Terms {
+			z := rng.NormFloat64()
+			logit += (term.Coefficient + z*s.standardErrors[term.Name]) * term.Value
+		}
+		probabilities[i] = logistic(logit)
+	}
+	return nil
+}
+
+// bmi draws the body mass index of the patient with measurement noise
+func (s *simulation) bmi(rng *rand.Rand) (float64, error) {
+	req := s.req
+	switch req.UnitSystems()[0] {
+	case UnitsBMI:
+		bmi := req.BMI + s.noise.BMI*rng.NormFloat64()
+		if bmi <= 0 {
+			return 0, fmt.Errorf("%w: bmi noise produced a bmi of %v", ErrOutOfDomain, bmi)
+		}
+		return bmi, nil
+	case UnitsMetric:
+		weight := req.WeightKg + s.noise.Weight*rng.NormFloat64()
+		height := req.HeightCm + s.noise.Height*rng.NormFloat64()
+		if weight <= 0 || height <= 0 {
+			return 0, fmt.Errorf("%w: input noise produced %v kg %v cm", ErrOutOfDomain, weight, height)
+		}
+		return calculateMetricBMI(weight, height), nil
+	default:
+		weight := float64(req.WeightLbs) + s.noise.Weight*rng.NormFloat64()
+		height := float64(req.HeightFt*12+req.HeightIn) + s.noise.Height*rng.NormFloat64()
+		if weight <= 0 || height <= 0 {
+			return 0, fmt.Errorf("%w: input noise produced %v lbs %v in", ErrOutOfDomain, weight, height)
+		}
+		return weight / math.Pow(height, 2.0) * 703, nil
+	}
+}
+
+// meanAndDeviation returns the mean and population standard deviation of
+// values. They are summed as differences from the first value, which keeps
+// the sums small and exact when every value is the same.
+func meanAndDeviation(values []float64) (mean, deviation float64) {
+	shift := values[0]
+	sum, squares := 0.0, 0.0
+	for _, v := range values {
+		sum += v - shift
+		squares += (v - shift) * (v - shift)
+	}
+	n := float64(len(values))
+	variance := squares/n - (sum/n)*(sum/n)
+	return shift + sum/n, math.Sqrt(math.Max(variance, 0))
+}
+
+// percentile returns the nearest-rank percentile p of sorted, so it is always
+// one of the draws
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+// histogram counts sorted into buckets of equal width from its lowest to its
+// highest value, or a single bucket when every value is the same
+func histogram(sorted []float64, buckets int) []HistogramBucket {
+	low, high := sorted[0], sorted[len(sorted)-1]
+	if low == high {
+		return []HistogramBucket{{FromPercent: low, ToPercent: high, Count: len(sorted)}}
+	}
+
+	width := (high - low) / float64(buckets)
+	histogram := make([]HistogramBucket, buckets)
+	for i := range histogram {
+		histogram[i].FromPercent = low + float64(i)*width
+		histogram[i].ToPercent = low + float64(i+1)*width
+	}
+	histogram[buckets-1].ToPercent = high
+	for _, v := range sorted {
+		i := min(max(int((v-low)/width), 0), buckets-1)
+		histogram[i].Count++
+	}
+	return histogram
+}
@@ -0,0 +1,281 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestSimulate_WithoutUncertainty(t *testing.T) {
+	// The embedded formulas have no variance data
+	req := SimulationRequest{Base: scenario1Request(), Draws: 100}
+	if _, err := testCalculator(t).Simulate(context.Background(), req); !errors.Is(err, ErrOutOfDomain) {
+		t.Errorf("Expected ErrOutOfDomain, got %v", err)
+	}
+
+	req.StandardErrors = map[string]float64{"intercept": 0}
+	if _, err := testCalculator(t).Simulate(context.Background(), req); !errors.Is(err, ErrOutOfDomain) {
+		t.Errorf("Expected ErrOutOfDomain for zero standard errors, got %v", err)
+	}
+}
+
+func TestSimulate_UnusedCoefficient(t *testing.T) {
+	// The patient has no tubal factor, so the coefficient never moves a draw
+	req := SimulationRequest{Base: scenario1Request(), Draws: 100, StandardErrors: map[string]float64{"tubal_factor_true": 0.5}}
+	result, err := testCalculator(t).Simulate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+
+	if want := 62.21; result.CumulativeChancePercent != want {
+		t.Errorf("Expected a point estimate of %v, got %v", want, result.CumulativeChancePercent)
+	}
+	if result.Mean != result.CumulativeChancePercent || result.StandardDeviation != 0 {
+		t.Errorf("Expected mean %v and no deviation, got %v and %v", result.CumulativeChancePercent, result.Mean, result.StandardDeviation)
+	}
+	for _, p := range result.Percentiles {
+		if p.CumulativeChancePercent != result.CumulativeChancePercent {
+			t.Errorf("Expected percentile %v at the point estimate, got %v", p.Percentile, p.CumulativeChancePercent)
+		}
+	}
+	if len(result.Percentiles) != len(DefaultSimulationPercentiles) {
+		t.Errorf("Expected the default percentiles, got %+v", result.Percentiles)
+	}
+	if len(result.Histogram) != 1 || result.Histogram[0].Count != req.Draws {
+		t.Errorf("Expected a single bucket of every draw, got %+v", result.Histogram)
+	}
+	if len(result.StandardErrors) != 1 || result.FormulaID != "1-3" || result.Model != CDCModelName {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestSimulate_CoefficientUncertainty(t *testing.T) {
+	calc := New(covarianceStore(t))
+	req := SimulationRequest{Base: scenario1Request(), Draws: 20000, Seed: 7, Percentiles: []float64{2.5, 50, 97.5}, Buckets: 10}
+
+	result, err := calc.Simulate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+
+	// The standard errors default to the square roots of the variances
+	if want := map[string]float64{"intercept": 0.2, "age_linear": 0.01}; !reflect.DeepEqual(result.StandardErrors, want) {
+		t.Errorf("Expected standard errors %v, got %v", want, result.StandardErrors)
+	}
+
+	lower, median, upper := result.Percentiles[0].CumulativeChancePercent, result.Percentiles[1].CumulativeChancePercent, result.Percentiles[2].CumulativeChancePercent
+	if !(lower < median && median < upper) {
+		t.Errorf("Expected ascending percentiles, got %+v", result.Percentiles)
+	}
+	if math.Abs(median-result.CumulativeChancePercent) > 1 {
+		t.Errorf("Expected a median near %v, got %v", result.CumulativeChancePercent, median)
+	}
+	if result.StandardDeviation <= 0 {
+		t.Errorf("Expected a spread, got standard deviation %v", result.StandardDeviation)
+	}
+
+	count := 0
+	for i, bucket := range result.Histogram {
+		count += bucket.Count
+		if i > 0 && bucket.FromPercent != result.Histogram[i-1].ToPercent {
+			t.Errorf("Expected bucket %d to start where bucket %d ends, got %+v", i, i-1, result.Histogram)
+		}
+	}
+	if len(result.Histogram) != req.Buckets || count != req.Draws {
+		t.Errorf("Expected %d buckets of %d draws, got %d buckets of %d", req.Buckets, req.Draws, len(result.Histogram), count)
+	}
+	if result.Histogram[0].FromPercent > lower || result.Histogram[len(result.Histogram)-1].ToPercent < upper {
+		t.Errorf("Expected the histogram to span the percentiles, got %+v", result.Histogram)
+	}
+}
+
+func TestSimulate_Deterministic(t *testing.T) {
+	calc := New(covarianceStore(t))
+	req := SimulationRequest{
+		Base:       scenario1Request(),
+		Draws:      3*simulationChunk + 5,
+		Seed:       42,
+		InputNoise: InputNoise{Weight: 3, Height: 0.5},
+	}
+
+	first, err := calc.Simulate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+
+	// The result must not depend on how many goroutines share the chunks
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	single, err := calc.Simulate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+	if !reflect.DeepEqual(first, single) {
+		t.Errorf("Expected the same result on one goroutine, got %+v and %+v", first, single)
+	}
+
+	req.Seed = 43
+	other, err := calc.Simulate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+	if reflect.DeepEqual(first.Percentiles, other.Percentiles) {
+		t.Errorf("Expected another seed to give other draws, got %+v", other.Percentiles)
+	}
+}
+
+func TestSimulate_Rounding(t *testing.T) {
+	calc := New(covarianceStore(t))
+	req := SimulationRequest{Base: scenario1Request(), Draws: 5000, Seed: 3, Buckets: 10}
+
+	req.Base.Rounding = RoundRaw
+	raw, err := calc.Simulate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+	req.Base.Rounding = RoundInteger
+	integer, err := calc.Simulate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+
+	// The draws are the same, only the reported chances are rounded by the
+	// policy; the deviation is rounded half-even to 2 decimal places
+	if want := math.RoundToEven(raw.Mean); integer.Mean != want {
+		t.Errorf("Expected the raw mean %v rounded to %v, got %v", raw.Mean, want, integer.Mean)
+	}
+	if integer.StandardDeviation != raw.StandardDeviation || integer.StandardDeviation != math.RoundToEven(integer.StandardDeviation*100)/100 {
+		t.Errorf("Expected the deviation %v to 2 decimal places, got %v", raw.StandardDeviation, integer.StandardDeviation)
+	}
+	for i, p := range integer.Percentiles {
+		if want := math.RoundToEven(raw.Percentiles[i].CumulativeChancePercent); p.CumulativeChancePercent != want {
+			t.Errorf("Expected percentile %v of %v, got %v", p.Percentile, want, p.CumulativeChancePercent)
+		}
+	}
+	if len(integer.Histogram) != len(raw.Histogram) {
+		t.Fatalf("Expected %d buckets, got %+v", len(raw.Histogram), integer.Histogram)
+	}
+	if !reflect.DeepEqual(integer.Histogram, raw.Histogram) {
+		t.Errorf("Expected the histogram not to depend on the policy, got %+v and %+v", integer.Histogram, raw.Histogram)
+	}
+}
+
+func TestSimulate_HistogramBoundsStraddlingIntegers(t *testing.T) {
+	// Buckets well under a percent wide, most of them across a whole percent
+	req := SimulationRequest{Base: scenario1Request(), Draws: 5000, Seed: 5, Buckets: 100}
+	req.Base.Rounding = RoundInteger
+	result, err := New(covarianceStore(t)).Simulate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+
+	straddling := 0
+	for i, bucket := range result.Histogram {
+		if !(bucket.FromPercent < bucket.ToPercent) {
+			t.Errorf("Expected bucket %d to have a width, got %+v", i, bucket)
+		}
+		if i > 0 && bucket.FromPercent != result.Histogram[i-1].ToPercent {
+			t.Errorf("Expected bucket %d to start where bucket %d ends, got %+v and %+v", i, i-1, result.Histogram[i-1], bucket)
+		}
+		if math.Floor(bucket.FromPercent) != math.Floor(bucket.ToPercent) {
+			straddling++
+		}
+	}
+	if straddling == 0 || straddling == len(result.Histogram) {
+		t.Errorf("Expected some buckets to straddle a whole percent, got %+v", result.Histogram)
+	}
+}
+
+func TestSimulate_InputNoise(t *testing.T) {
+	metric := scenario1Request()
+	metric.WeightLbs, metric.HeightFt, metric.HeightIn = 0, 0, 0
+	metric.WeightKg, metric.HeightCm = 64, 167.6
+	bmi := scenario1Request()
+	bmi.WeightLbs, bmi.HeightFt, bmi.HeightIn = 0, 0, 0
+	bmi.BMI = 22.8
+
+	tests := []struct {
+		name  string
+		base  CalculateRequest
+		noise InputNoise
+	}{
+		{name: "imperial", base: scenario1Request(), noise: InputNoise{Weight: 5}},
+		{name: "metric", base: metric, noise: InputNoise{Weight: 2, Height: 1}},
+		{name: "bmi", base: bmi, noise: InputNoise{BMI: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The formulas have no variance data, so only the inputs vary
+			result, err := testCalculator(t).Simulate(context.Background(), SimulationRequest{Base: tt.base, Draws: 1000, InputNoise: tt.noise})
+			if err != nil {
+				t.Fatalf("Simulate returned error: %v", err)
+			}
+			if result.StandardDeviation <= 0 || len(result.Histogram) != DefaultSimulationBuckets {
+				t.Errorf("Expected draws to vary with the inputs, got %+v", result)
+			}
+			if !slices.IsSortedFunc(result.Percentiles, func(a, b SimulationPercentile) int {
+				return int(math.Copysign(1, a.CumulativeChancePercent-b.CumulativeChancePercent))
+			}) {
+				t.Errorf("Expected ascending percentiles, got %+v", result.Percentiles)
+			}
+		})
+	}
+}
+
+func TestSimulate_ExtremeLogits(t *testing.T) {
+	// Age multiplies its coefficient by 32, so draws reach logits of several
+	// hundred, where a naive logistic overflows to NaN
+	req := SimulationRequest{Base: scenario1Request(), Draws: 2000, StandardErrors: map[string]float64{"intercept": MaxStandardError, "age_linear": MaxStandardError}}
+	result, err := testCalculator(t).Simulate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+
+	if math.IsNaN(result.Mean) || result.Percentiles[0].CumulativeChancePercent > 1 || result.Percentiles[len(result.Percentiles)-1].CumulativeChancePercent < 99 {
+		t.Errorf("Expected draws from 0%% to 100%%, got mean %v and %+v", result.Mean, result.Percentiles)
+	}
+	count := 0
+	for _, bucket := range result.Histogram {
+		count += bucket.Count
+	}
+	if count != req.Draws {
+		t.Errorf("Expected %d draws in the histogram, got %+v", req.Draws, result.Histogram)
+	}
+}
+
+func TestSimulate_Errors(t *testing.T) {
+	calc := New(covarianceStore(t))
+	if err := calc.RegisterModel(fixedModel{name: "clinic", probability: 0.5}); err != nil {
+		t.Fatalf("RegisterModel returned error: %v", err)
+	}
+	clinic := scenario1Request()
+	clinic.Model = "clinic"
+	bmi := scenario1Request()
+	bmi.WeightLbs, bmi.HeightFt, bmi.HeightIn = 0, 0, 0
+	bmi.BMI = 22.8
+
+	tests := []struct {
+		name string
+		req  SimulationRequest
+	}{
+		{name: "no draws", req: SimulationRequest{Base: scenario1Request()}},
+		{name: "too many draws", req: SimulationRequest{Base: scenario1Request(), Draws: MaxSimulationDraws + 1}},
+		{name: "model without coefficients", req: SimulationRequest{Base: clinic, Draws: 10}},
+		{name: "unknown coefficient", req: SimulationRequest{Base: scenario1Request(), Draws: 10, StandardErrors: map[string]float64{"height_linear": 0.1}}},
+		{name: "negative standard error", req: SimulationRequest{Base: scenario1Request(), Draws: 10, StandardErrors: map[string]float64{"intercept": -0.1}}},
+		{name: "standard error too large", req: SimulationRequest{Base: scenario1Request(), Draws: 10, StandardErrors: map[string]float64{"intercept": 1000}}},
+		{name: "noise of another unit system", req: SimulationRequest{Base: bmi, Draws: 10, InputNoise: InputNoise{Weight: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := calc.Simulate(context.Background(), tt.req); !errors.Is(err, ErrOutOfDomain) {
+				t.Errorf("Expected ErrOutOfDomain, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+)
+
+// Unit systems a request can describe body measurements in. A request must use
+// exactly one of them.
+const (
+	UnitsImperial = "imperial" // weightLbs, heightFt and heightIn
+	UnitsMetric   = "metric"   // weightKg and heightCm
+	UnitsBMI      = "bmi"      // bmi supplied directly
+)
+
+// UnitSystems returns every unit system the request sets at least one field of
+func (req CalculateRequest) UnitSystems() []string {
+	var systems []string
+	if req.WeightLbs != 0 || req.HeightFt != 0 || req.HeightIn != 0 {
+		systems = append(systems, UnitsImperial)
+	}
+	if req.WeightKg != 0 || req.HeightCm != 0 {
+		systems = append(systems, UnitsMetric)
+	}
+	if req.BMI != 0 {
+		systems = append(systems, UnitsBMI)
+	}
+	return systems
+}
+
+// BodyMassIndex computes BMI from whichever unit system the request uses
+func (req CalculateRequest) BodyMassIndex() (float64, error) {
+	return bodyMassIndex(req)
+}
+
+// bodyMassIndex computes BMI from whichever unit system the request uses
+func bodyMassIndex(req CalculateRequest) (float64, error) {
+	systems := req.UnitSystems()
+	if len(systems) != 1 {
+		return 0, fmt.Errorf("%w: expected exactly one unit system, got %v", ErrOutOfDomain, systems)
+	}
+
+	switch systems[0] {
+	case UnitsMetric:
+		if req.WeightKg <= 0 || req.HeightCm <= 0 {
+			return 0, fmt.Errorf("%w: weight and height must be positive, got %v kg %v cm", ErrOutOfDomain, req.WeightKg, req.HeightCm)
+		}
+		return calculateMetricBMI(req.WeightKg, req.HeightCm), nil
+	case UnitsBMI:
+		if req.BMI <= 0 {
+			return 0, fmt.Errorf("%w: bmi must be positive, got %v", ErrOutOfDomain, req.BMI)
+		}
+		return req.BMI, nil
+	default:
+		if req.WeightLbs <= 0 {
+			return 0, fmt.Errorf("%w: weight must be positive, got %d", ErrOutOfDomain, req.WeightLbs)
+		}
+		if req.HeightFt*12+req.HeightIn <= 0 {
+			return 0, fmt.Errorf("%w: height must be positive, got %d ft %d in", ErrOutOfDomain, req.HeightFt, req.HeightIn)
+		}
+		return calculateBMI(req.WeightLbs, req.HeightFt, req.HeightIn), nil
+	}
+}
+
+// calculateBMI computes BMI from weight in pounds and height in inches
+func calculateBMI(weightLbs, heightFt int, heightIn int) float64 {
+	return float64(weightLbs) / math.Pow(float64(heightFt*12+heightIn), 2.0) * 703
+}
+
+// calculateMetricBMI computes BMI from weight in kilograms and height in centimeters
+func calculateMetricBMI(weightKg, heightCm float64) float64 {
+	return weightKg / math.Pow(heightCm/100.0, 2.0)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ivf-calculator-backend/internal/batch"
+	"ivf-calculator-backend/internal/i18n"
+	"ivf-calculator-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// BatchHandler serves the batch calculate endpoint
+type BatchHandler struct {
+	processor *batch.Processor
+	maxSize   int
+}
+
+// NewBatchHandler creates a handler that accepts at most maxSize items per request
+func NewBatchHandler(processor *batch.Processor, maxSize int) *BatchHandler {
+	return &BatchHandler{processor: processor, maxSize: maxSize}
+}
+
+// PostBatch handles POST /api/calculate/batch requests. The body is an array
+// of calculate requests, each with an "id"; problems with an item are
+// reported in its result rather than failing the whole batch.
+func (h *BatchHandler) PostBatch(c *gin.Context) {
+	var raw []json.RawMessage
+
+	if err := c.ShouldBindJSON(&raw); err != nil {
+		respondValidationErrors(c, validation.BindingErrors(err, raw))
+		return
+	}
+
+	if len(raw) > h.maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   localizedText(c, "error.batch_too_large", "batch too large"),
+			"details": fmt.Sprintf("at most %d items are allowed, got %d", h.maxSize, len(raw)),
+		})
+		return
+	}
+
+	// Decode each item separately so one malformed item does not fail the batch
+	results := make([]batch.Result, len(raw))
+	items := make([]batch.Item, 0, len(raw))
+	positions := make([]int, 0, len(raw))
+	for i, data := range raw {
+		var item batch.Item
+		if err := json.Unmarshal(data, &item); err != nil {
+			results[i] = batch.Result{ID: item.ID, Errors: validation.BindingErrors(err, item)}
+			continue
+		}
+		if err := binding.Validator.ValidateStruct(&item.CalculateRequest); err != nil {
+			results[i] = batch.Result{ID: item.ID, Errors: validation.BindingErrors(err, item.CalculateRequest)}
+			continue
+		}
+		queryRounding(c, &item.CalculateRequest)
+		items = append(items, item)
+		positions = append(positions, i)
+	}
+
+	for i, result := range h.processor.Run(c.Request.Context(), items) {
+		results[positions[i]] = result
+	}
+	locale := requestLocale(c)
+	for i := range results {
+		results[i].Errors = results[i].Errors.Localize(i18n.Default(), locale)
+		results[i].Warnings = results[i].Warnings.Localize(i18n.Default(), locale)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+	})
+}
+
+// PostBatchCSV handles POST /api/calculate/batch/csv requests. The body is a
+// CSV of patients which is streamed back with result columns appended.
+func (h *BatchHandler) PostBatchCSV(c *gin.Context) {
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="results.csv"`)
+
+	err := h.processor.ProcessCSV(c.Request.Context(), c.Request.Body, c.Writer)
+	if err == nil {
+		return
+	}
+
+	// Once rows have been streamed the status is already sent, and ProcessCSV
+	// has reported the error in a final row
+	if c.Writer.Written() {
+		log.Printf("batch CSV failed after streaming started: %v", err)
+		return
+	}
+
+	c.Writer.Header().Del("Content-Type")
+	c.Writer.Header().Del("Content-Disposition")
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   localizedText(c, "error.invalid_csv", "invalid CSV"),
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"ivf-calculator-backend/internal/batch"
+)
+
+func TestPostBatch_Positions(t *testing.T) {
+	handler := NewBatchHandler(batch.NewProcessor(testCalculator(t), 2), 10).PostBatch
+	valid := func(id string) string {
+		return strings.Replace(scenario1Body, "{", `{"id": "`+id+`",`, 1)
+	}
+
+	// Items that fail to decode, bind or validate sit between items that are calculated
+	body := "[" + strings.Join([]string{
+		`{"id": "malformed", "age": "thirty"}`,
+		valid("a"),
+		`{"id": "unbound", "bmi": 22, "eggSource": "donor", "reasons": ["unknown"]}`,
+		valid("b"),
+		`{"id": "invalid", "age": 12, "bmi": 22, "eggSource": "donor", "reasons": ["unknown"]}`,
+		valid("c"),
+	}, ",") + "]"
+
+	w := serve(handler, http.MethodPost, "/", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Results []batch.Result `json:"results"`
+	}
+	decode(t, w, &response)
+
+	wantIDs := []string{"malformed", "a", "unbound", "b", "invalid", "c"}
+	if len(response.Results) != len(wantIDs) {
+		t.Fatalf("Expected %d results, got %d", len(wantIDs), len(response.Results))
+	}
+	for i, result := range response.Results {
+		if result.ID != wantIDs[i] {
+			t.Errorf("Result %d: expected id %q, got %q", i, wantIDs[i], result.ID)
+		}
+		calculated := i%2 == 1
+		if calculated && (result.Result == nil || result.Result.CumulativeChancePercent != 62.21) {
+			t.Errorf("Result %d: expected 62.21, got %+v", i, result)
+		}
+		if !calculated && (result.Result != nil || len(result.Errors) == 0) {
+			t.Errorf("Result %d: expected errors only, got %+v", i, result)
+		}
+	}
+}
+
+func TestPostBatch_TooLarge(t *testing.T) {
+	handler := NewBatchHandler(batch.NewProcessor(testCalculator(t), 1), 1).PostBatch
+
+	w := serve(handler, http.MethodPost, "/", "["+scenario1Body+","+scenario1Body+"]", nil)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+}
+
+func TestPostBatchCSV_BadRowInTheMiddle(t *testing.T) {
+	handler := NewBatchHandler(batch.NewProcessor(testCalculator(t), 1), 10).PostBatchCSV
+
+	var body strings.Builder
+	body.WriteString("id,age,bmi,priorPregnancies,priorBirths,reasons,eggSource\n")
+	for i := 0; i < 100; i++ {
+		body.WriteString("ok,32,22.8,0,0,unknown,donor\n")
+	}
+	body.WriteString("bad,3\"2,22.8,0,0,unknown,donor\nafter,32,22.8,0,0,unknown,donor\n")
+
+	// The status is sent with the first rows, so the error is in the last row
+	w := serve(handler, http.MethodPost, "/", body.String(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if last := lines[len(lines)-1]; !strings.Contains(last, "error: parse error on line 102") || strings.HasPrefix(last, "after") {
+		t.Errorf("Expected the output to end with the parse error, got %q", last)
+	}
+}
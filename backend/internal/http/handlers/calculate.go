@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 
 	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/i18n"
 	"ivf-calculator-backend/internal/validation"
 
 	"github.com/gin-gonic/gin"
@@ -14,28 +18,101 @@ import (
 type CalculateRequest = calculator.CalculateRequest
 type CalculateResponse = calculator.CalculateResponse
 
+// calculateResult is a calculation with the caveats of its inputs
+type calculateResult struct {
+	CalculateResponse
+	Warnings validation.Warnings `json:"warnings,omitempty"`
+}
+
+// CalculateHandler serves the calculate endpoints using a Calculator
+type CalculateHandler struct {
+	calc *calculator.Calculator
+}
+
+// NewCalculateHandler creates a handler backed by calc
+func NewCalculateHandler(calc *calculator.Calculator) *CalculateHandler {
+	return &CalculateHandler{calc: calc}
+}
+
 // PostCalculate handles POST /api/calculate requests
-func PostCalculate(c *gin.Context) {
+func (h *CalculateHandler) PostCalculate(c *gin.Context) {
 	var req CalculateRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request format",
-			"details": err.Error(),
-		})
+		respondValidationErrors(c, validation.BindingErrors(err, req))
 		return
 	}
+	queryRounding(c, &req)
 
 	// Validate the request
-	if errors := validation.ValidateCalculateRequest(req); len(errors) > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"details": errors,
-		})
+	if errors := validation.ValidateCalculateRequest(req, h.calc.MaxRetrievals()); len(errors) > 0 {
+		respondValidationErrors(c, errors)
 		return
 	}
 
 	// Calculate the result
-	result := calculator.Calculate(req)
+	result, err := h.calc.Calculate(c.Request.Context(), req)
+	if err != nil {
+		respondCalculateError(c, err)
+		return
+	}
+
+	// Only include the per-term breakdown when requested with ?explain=true
+	if explain, _ := strconv.ParseBool(c.Query("explain")); !explain {
+		result.Breakdown = nil
+	}
+
+	c.JSON(http.StatusOK, calculateResult{
+		CalculateResponse: result,
+		Warnings:          validation.WarnCalculateRequest(req).Localize(i18n.Default(), requestLocale(c)),
+	})
+}
+
+// GetVersions handles GET /api/calculate/versions requests
+func (h *CalculateHandler) GetVersions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"versions": h.calc.Versions(),
+	})
+}
+
+// GetModels handles GET /api/calculate/models requests
+func (h *CalculateHandler) GetModels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"models": h.calc.Models(),
+	})
+}
+
+// queryRounding applies the ?rounding= query parameter to a request whose body
+// does not set rounding
+func queryRounding(c *gin.Context, req *CalculateRequest) {
+	if req.Rounding == "" {
+		req.Rounding = c.Query("rounding")
+	}
+}
+
+// respondValidationErrors responds to a malformed or invalid request with the
+// list of problems, the same shape whether binding or validation failed
+func respondValidationErrors(c *gin.Context, errors validation.Errors) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"errors": errors.Localize(i18n.Default(), requestLocale(c)),
+	})
+}
 
-	c.JSON(http.StatusOK, result)
+// respondCalculateError maps calculator errors to HTTP responses. Inputs the
+// formulas cannot handle are 422s; anything else is a server-side failure.
+func respondCalculateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, calculator.ErrNoMatchingFormula), errors.Is(err, calculator.ErrOutOfDomain),
+		errors.Is(err, calculator.ErrUnknownModel), errors.Is(err, calculator.ErrUnknownModelVersion),
+		errors.Is(err, calculator.ErrUnsupportedRetrievals):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   localizedText(c, "error.unable_to_calculate", "unable to calculate for the given parameters"),
+			"details": err.Error(),
+		})
+	default:
+		log.Printf("calculate failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": localizedText(c, "error.calculation_unavailable", "calculation unavailable"),
+		})
+	}
 }
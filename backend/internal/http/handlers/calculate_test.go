@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ivf-calculator-backend/internal/calculator"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testCalculator returns a Calculator backed by the embedded CDC formulas
+func testCalculator(t *testing.T) *calculator.Calculator {
+	t.Helper()
+	store, err := calculator.DefaultFormulaStore()
+	if err != nil {
+		t.Fatalf("Failed to load formulas: %v", err)
+	}
+	return calculator.New(store)
+}
+
+// serve sends a request with a JSON body to handler and returns the recorded response
+func serve(handler gin.HandlerFunc, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.Handle(method, "/", handler)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals the body of a response
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("Failed to decode response %q: %v", w.Body.String(), err)
+	}
+}
+
+const scenario1Body = `{"age": 32, "weightLbs": 141, "heightFt": 5, "heightIn": 6, "priorIvfCycles": "no",
+	"priorPregnancies": 1, "priorBirths": 1, "reasons": ["endometriosis", "ovulatory_disorder"], "eggSource": "own"}`
+
+func TestRespondCalculateError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantError   string
+		wantDetails bool
+	}{
+		{"no matching formula", calculator.ErrNoMatchingFormula, http.StatusUnprocessableEntity, "unable to calculate for the given parameters", true},
+		{"out of domain", fmt.Errorf("%w: age must be positive", calculator.ErrOutOfDomain), http.StatusUnprocessableEntity, "unable to calculate for the given parameters", true},
+		{"unknown model", calculator.ErrUnknownModel, http.StatusUnprocessableEntity, "unable to calculate for the given parameters", true},
+		{"unknown model version", calculator.ErrUnknownModelVersion, http.StatusUnprocessableEntity, "unable to calculate for the given parameters", true},
+		{"unsupported retrievals", calculator.ErrUnsupportedRetrievals, http.StatusUnprocessableEntity, "unable to calculate for the given parameters", true},
+		{"no formulas", calculator.ErrNoFormulas, http.StatusInternalServerError, "calculation unavailable", false},
+		{"unexpected", errors.New("disk on fire"), http.StatusInternalServerError, "calculation unavailable", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(func(c *gin.Context) { respondCalculateError(c, tt.err) }, http.MethodGet, "/", "", nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			var body map[string]string
+			decode(t, w, &body)
+			if body["error"] != tt.wantError {
+				t.Errorf("Expected error %q, got %q", tt.wantError, body["error"])
+			}
+			// Details of server-side failures are logged, not returned
+			if _, ok := body["details"]; ok != tt.wantDetails {
+				t.Errorf("Expected details %v, got %q", tt.wantDetails, body["details"])
+			}
+		})
+	}
+}
+
+func TestPostCalculate_Explain(t *testing.T) {
+	handler := NewCalculateHandler(testCalculator(t)).PostCalculate
+
+	tests := []struct {
+		target        string
+		wantBreakdown bool
+	}{
+		{"/", false},
+		{"/?explain=false", false},
+		{"/?explain=true", true},
+		{"/?explain=1", true},
+		{"/?explain=maybe", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := serve(handler, http.MethodPost, tt.target, scenario1Body, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var result calculateResult
+			decode(t, w, &result)
+			if result.CumulativeChancePercent != 62.21 {
+				t.Errorf("Expected 62.21, got %v", result.CumulativeChancePercent)
+			}
+			if (result.Breakdown != nil) != tt.wantBreakdown {
+				t.Errorf("Expected breakdown %v, got %+v", tt.wantBreakdown, result.Breakdown)
+			}
+		})
+	}
+}
+
+func TestPostCalculate_Errors(t *testing.T) {
+	calc := testCalculator(t)
+	if err := calc.RegisterModel(failingModel{}); err != nil {
+		t.Fatalf("RegisterModel returned error: %v", err)
+	}
+	handler := NewCalculateHandler(calc).PostCalculate
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"malformed", `{"age": "thirty"}`, http.StatusBadRequest},
+		{"invalid", `{"age": 32}`, http.StatusBadRequest},
+		{"more retrievals than the formulas estimate", strings.Replace(scenario1Body, "{", `{"retrievals": 2,`, 1), http.StatusBadRequest},
+		{"unknown model version", strings.Replace(scenario1Body, "{", `{"modelVersion": "1999",`, 1), http.StatusUnprocessableEntity},
+		{"model failure", strings.Replace(scenario1Body, "{", `{"model": "failing",`, 1), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(handler, http.MethodPost, "/", tt.body, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestPostCalculate_DefaultFormulasRetrievals checks the bundled CDC CSV, which
+// has no retrieval columns, rejects more than one retrieval and says why
+func TestPostCalculate_DefaultFormulasRetrievals(t *testing.T) {
+	handler := NewCalculateHandler(testCalculator(t))
+	body := strings.Replace(scenario1Body, "{", `{"retrievals": 2,`, 1)
+
+	for name, post := range map[string]gin.HandlerFunc{"calculate": handler.PostCalculate, "compare": handler.PostCompare} {
+		w := serve(post, http.MethodPost, "/", body, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+		var result struct {
+			Errors []struct {
+				Field   string         `json:"field"`
+				Message string         `json:"message"`
+				Params  map[string]any `json:"params"`
+			} `json:"errors"`
+		}
+		decode(t, w, &result)
+		want := "must be between 1 and 1, the most retrievals the loaded formulas can estimate"
+		if len(result.Errors) != 1 || result.Errors[0].Field != "retrievals" || result.Errors[0].Message != want || result.Errors[0].Params["max"] != 1.0 {
+			t.Errorf("%s: expected a retrievals error, got %+v", name, result.Errors)
+		}
+	}
+
+	var schema SchemaResponse
+	decode(t, serve(handler.GetSchema, http.MethodGet, "/", "", nil), &schema)
+	if rule, ok := schema.Rule("retrievals"); !ok || rule.Max == nil || *rule.Max != 1 {
+		t.Errorf("Expected the schema to accept 1 retrieval, got %+v", rule)
+	}
+}
+
+// failingModel fails every prediction for a reason other than the inputs
+type failingModel struct{}
+
+func (failingModel) Info() calculator.ModelInfo {
+	return calculator.ModelInfo{Name: "failing", Version: "1", Description: "always fails", Covariates: []string{}}
+}
+
+func (failingModel) Predict(ctx context.Context, patient calculator.Patient) (calculator.Prediction, error) {
+	return calculator.Prediction{}, errors.New("model backend unreachable")
+}
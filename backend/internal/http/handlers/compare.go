@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/i18n"
+	"ivf-calculator-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compareOption is an option of a comparison with the caveats of its inputs
+type compareOption struct {
+	calculator.CompareOption
+	Warnings validation.Warnings `json:"warnings,omitempty"`
+}
+
+// PostCompare handles POST /api/calculate/compare requests. The body is a
+// calculate request without eggSource; the response has a result for own
+// eggs and for donor eggs.
+func (h *CalculateHandler) PostCompare(c *gin.Context) {
+	var req CalculateRequest
+
+	// Decode without binding validation, which requires the eggSource being compared
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		respondValidationErrors(c, validation.BindingErrors(err, req))
+		return
+	}
+	queryRounding(c, &req)
+
+	if errors := validation.ValidateCompareRequest(req, h.calc.MaxRetrievals()); len(errors) > 0 {
+		respondValidationErrors(c, errors)
+		return
+	}
+
+	options, err := h.calc.Compare(c.Request.Context(), req)
+	if err != nil {
+		respondCalculateError(c, err)
+		return
+	}
+
+	locale := requestLocale(c)
+	results := make([]compareOption, len(options))
+	for i, optionReq := range calculator.CompareOptions(req) {
+		// The breakdown is only returned by the single calculate endpoint
+		options[i].Breakdown = nil
+		results[i] = compareOption{
+			CompareOption: options[i],
+			Warnings:      validation.WarnCalculateRequest(optionReq).Localize(i18n.Default(), locale),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"options": results,
+	})
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PostCurve handles POST /api/calculate/curve requests
+func (h *CalculateHandler) PostCurve(c *gin.Context) {
+	var req calculator.CurveRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondValidationErrors(c, validation.BindingErrors(err, req))
+		return
+	}
+	queryRounding(c, &req.Base)
+
+	// Validate the base request and every point of the curve
+	if errors := validation.ValidateCurveRequest(req, h.calc.MaxRetrievals()); len(errors) > 0 {
+		respondValidationErrors(c, errors)
+		return
+	}
+
+	result, err := h.calc.Curve(c.Request.Context(), req)
+	if err != nil {
+		respondCalculateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ivf-calculator-backend/internal/i18n"
+	"ivf-calculator-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestLocale selects the locale of the response from the locale query
+// parameter, then the Accept-Language header, defaulting to English
+func requestLocale(c *gin.Context) string {
+	locale := i18n.Default().Match(c.Query("locale"), c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", locale)
+	return locale
+}
+
+// localizedText returns the message for key in the request's locale
+func localizedText(c *gin.Context, key, fallback string) string {
+	return i18n.Default().Text(requestLocale(c), key, fallback)
+}
+
+// Reason is an infertility reason with its display name
+type Reason struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// GetReasons handles GET /api/calculate/reasons requests, listing the accepted
+// reasons with display names in the request's locale
+func GetReasons(c *gin.Context) {
+	locale := requestLocale(c)
+	reasons := make([]Reason, len(validation.Reasons))
+	for i, reason := range validation.Reasons {
+		reasons[i] = Reason{Value: reason, Label: i18n.Default().ReasonName(locale, reason)}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"locale":  locale,
+		"reasons": reasons,
+	})
+}
+
+// SchemaResponse is the validation schema of calculate requests with labels in
+// the response's locale
+type SchemaResponse struct {
+	Locale string `json:"locale"`
+	validation.Schema
+}
+
+// GetSchema handles GET /api/calculate/schema requests, describing the fields
+// of a calculate request and the rules they are validated against with the
+// loaded formulas
+func (h *CalculateHandler) GetSchema(c *gin.Context) {
+	locale := requestLocale(c)
+	schema := validation.CalculateRequestSchema.WithMaxRetrievals(h.calc.MaxRetrievals())
+	c.JSON(http.StatusOK, SchemaResponse{
+		Locale: locale,
+		Schema: schema.Localize(i18n.Default(), locale),
+	})
+}
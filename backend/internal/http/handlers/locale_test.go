@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestLocale(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		acceptLanguage string
+		want           string
+	}{
+		{"default", "/", "", "en"},
+		{"header", "/", "es", "es"},
+		{"regional header", "/", "fr-CA,fr;q=0.9", "fr"},
+		{"header preference order", "/", "de;q=0.9,zh;q=0.8,es;q=0.5", "zh"},
+		{"unsupported header", "/", "de-DE", "en"},
+		{"query", "/?locale=zh", "", "zh"},
+		{"query overrides header", "/?locale=es", "fr", "es"},
+		{"unsupported query falls back to header", "/?locale=de", "fr", "fr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			header := http.Header{}
+			if tt.acceptLanguage != "" {
+				header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := serve(func(c *gin.Context) { got = requestLocale(c) }, http.MethodGet, tt.target, "", header)
+
+			if got != tt.want {
+				t.Errorf("Expected locale %q, got %q", tt.want, got)
+			}
+			if language := w.Header().Get("Content-Language"); language != tt.want {
+				t.Errorf("Expected Content-Language %q, got %q", tt.want, language)
+			}
+		})
+	}
+}
+
+func TestRespondValidationErrors_Localized(t *testing.T) {
+	handler := NewCalculateHandler(testCalculator(t)).PostCalculate
+
+	w := serve(handler, http.MethodPost, "/?locale=es", `{"age": 32, "bmi": 22, "eggSource": "donor"}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	var body struct {
+		Errors []struct {
+			Field   string `json:"field"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	decode(t, w, &body)
+	if len(body.Errors) != 1 || body.Errors[0].Field != "reasons" {
+		t.Fatalf("Expected a reasons error, got %+v", body.Errors)
+	}
+	if body.Errors[0].Message == "at least one reason must be selected" {
+		t.Errorf("Expected a Spanish message, got %q", body.Errors[0].Message)
+	}
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ivf-calculator-backend/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOpenAPI handles GET /api/openapi.json requests, documenting the
+// retrievals the loaded formulas estimate
+func (h *CalculateHandler) GetOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, openapi.Spec(h.calc.MaxRetrievals()))
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PostSimulate handles POST /api/calculate/simulate requests
+func (h *CalculateHandler) PostSimulate(c *gin.Context) {
+	var req calculator.SimulationRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondValidationErrors(c, validation.BindingErrors(err, req))
+		return
+	}
+	queryRounding(c, &req.Base)
+
+	// Validate the base request and the simulation settings
+	if errors := validation.ValidateSimulationRequest(req, h.calc.MaxRetrievals()); len(errors) > 0 {
+		respondValidationErrors(c, errors)
+		return
+	}
+
+	result, err := h.calc.Simulate(c.Request.Context(), req)
+	if err != nil {
+		respondCalculateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
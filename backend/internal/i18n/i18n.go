@@ -0,0 +1,220 @@
+// Package i18n holds the message catalog used to localize API messages.
+//
+// Each locale is a flat JSON file of message templates keyed by message id,
+// such as "validation.out_of_range" or "reason.unexplained". Templates refer
+// to params as {name}. A message can also be a list of templates, of which the
+// first whose params are all given is used. A message missing from a locale
+// falls back to English, which is the source of every English message.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// English is the default locale, used when no requested locale is supported
+const English = "en"
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// Catalog holds message templates for a set of locales
+type Catalog struct {
+	messages map[string]map[string][]string
+}
+
+// Default returns the catalog of the locales embedded in the binary
+var Default = sync.OnceValue(func() *Catalog {
+	catalog, err := LoadCatalog(localeFS, "locales")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: embedded locales: %v", err))
+	}
+	return catalog
+})
+
+// LoadCatalog loads every <locale>.json file in dir of fsys
+func LoadCatalog(fsys fs.FS, dir string) (*Catalog, error) {
+	paths, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := &Catalog{messages: make(map[string]map[string][]string)}
+	for _, p := range paths {
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		messages := make(map[string][]string, len(raw))
+		for key, value := range raw {
+			var template string
+			if err := json.Unmarshal(value, &template); err == nil {
+				messages[key] = []string{template}
+				continue
+			}
+			var templates []string
+			if err := json.Unmarshal(value, &templates); err != nil || len(templates) == 0 {
+				return nil, fmt.Errorf("%s: %s must be a template or a non-empty list of templates", p, key)
+			}
+			messages[key] = templates
+		}
+		catalog.messages[strings.TrimSuffix(path.Base(p), ".json")] = messages
+	}
+	if _, ok := catalog.messages[English]; !ok {
+		return nil, fmt.Errorf("no %s locale in %s", English, dir)
+	}
+	return catalog, nil
+}
+
+// Locales returns the supported locales, sorted
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// Match returns the first supported locale in preferences, which are either
+// locale tags such as "es-MX" or Accept-Language header values. Regional tags
+// match their base language. English is returned when nothing matches.
+func (c *Catalog) Match(preferences ...string) string {
+	for _, preference := range preferences {
+		for _, tag := range parseAcceptLanguage(preference) {
+			tag = strings.ToLower(tag)
+			if _, ok := c.messages[tag]; ok {
+				return tag
+			}
+			base, _, _ := strings.Cut(tag, "-")
+			if _, ok := c.messages[base]; ok {
+				return base
+			}
+		}
+	}
+	return English
+}
+
+// parseAcceptLanguage returns the language tags of an Accept-Language value
+// ordered by quality, most preferred first
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = v
+			}
+		}
+		if quality > 0 {
+			tags = append(tags, weighted{tag, quality})
+		}
+	}
+
+	slices.SortStableFunc(tags, func(a, b weighted) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// Message renders the template for key in locale, falling back to English.
+// It reports false when no locale has the key or a param it refers to is missing.
+func (c *Catalog) Message(locale, key string, params map[string]any) (string, bool) {
+	for _, l := range []string{locale, English} {
+		if _, ok := c.messages[l][key]; ok {
+			return c.LocaleMessage(l, key, params)
+		}
+	}
+	return "", false
+}
+
+// LocaleMessage renders the first template for key in locale whose params are
+// all given, without falling back to English
+func (c *Catalog) LocaleMessage(locale, key string, params map[string]any) (string, bool) {
+	for _, template := range c.messages[locale][key] {
+		if message, ok := render(template, params); ok {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+// Text returns the message for key in locale, or fallback when there is none
+func (c *Catalog) Text(locale, key, fallback string) string {
+	if message, ok := c.Message(locale, key, nil); ok {
+		return message
+	}
+	return fallback
+}
+
+// ReasonName returns the display name of an infertility reason
+func (c *Catalog) ReasonName(locale, reason string) string {
+	return c.Text(locale, "reason."+reason, reason)
+}
+
+// render replaces the {name} placeholders of template with params
+func render(template string, params map[string]any) (string, bool) {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), true
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			b.WriteString(template)
+			return b.String(), true
+		}
+
+		value, ok := params[template[start+1:start+end]]
+		if !ok {
+			return "", false
+		}
+		b.WriteString(template[:start])
+		b.WriteString(formatParam(value))
+		template = template[start+end+1:]
+	}
+}
+
+func formatParam(value any) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ", ")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
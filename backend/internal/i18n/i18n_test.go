@@ -0,0 +1,89 @@
+package i18n
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMatch(t *testing.T) {
+	catalog := Default()
+
+	tests := []struct {
+		name        string
+		preferences []string
+		want        string
+	}{
+		{"no preference", nil, English},
+		{"exact", []string{"fr"}, "fr"},
+		{"regional tag", []string{"es-MX"}, "es"},
+		{"case insensitive", []string{"ZH-cn"}, "zh"},
+		{"accept-language quality order", []string{"de;q=0.9, fr;q=0.5, es;q=0.8"}, "es"},
+		{"unsupported", []string{"de, it"}, English},
+		{"query before header", []string{"zh", "fr-CA,fr;q=0.9"}, "zh"},
+		{"empty query falls back to header", []string{"", "fr-CA,fr;q=0.9"}, "fr"},
+		{"zero quality is excluded", []string{"es;q=0, fr"}, "fr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := catalog.Match(tt.preferences...); got != tt.want {
+				t.Errorf("Match(%q) = %s, want %s", tt.preferences, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	catalog := Default()
+
+	if got, _ := catalog.Message("es", "validation.out_of_range", map[string]any{"min": 20, "max": 50}); got != "debe estar entre 20 y 50" {
+		t.Errorf("Unexpected Spanish message %q", got)
+	}
+	if got, _ := catalog.Message("fr", "validation.out_of_range", map[string]any{"min": 36.2, "max": 136.1}); got != "doit être compris entre 36.2 et 136.1" {
+		t.Errorf("Unexpected French message %q", got)
+	}
+	if got, _ := catalog.Message("es", "validation.out_of_range", map[string]any{"min": 0}); got != "debe ser como mínimo 0" {
+		t.Errorf("Expected the template with only min, got %q", got)
+	}
+	if _, ok := catalog.Message("es", "validation.out_of_range", map[string]any{"max": 50}); ok {
+		t.Error("Expected a missing param to fail")
+	}
+	if got := catalog.ReasonName("de", "unexplained"); got != "Unexplained (Idiopathic) infertility" {
+		t.Errorf("Expected English fallback, got %q", got)
+	}
+	if got := catalog.ReasonName("zh", "bogus"); got != "bogus" {
+		t.Errorf("Expected unknown reasons to keep their value, got %q", got)
+	}
+}
+
+// TestLocalesComplete checks every translation has the messages of English
+func TestLocalesComplete(t *testing.T) {
+	catalog := Default()
+	if got := catalog.Locales(); !slices.Equal(got, []string{"en", "es", "fr", "zh"}) {
+		t.Fatalf("Unexpected locales %v", got)
+	}
+
+	keys := func(locale string) []string {
+		var keys []string
+		for key := range catalog.messages[locale] {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		return keys
+	}
+
+	want := keys(English)
+	for _, locale := range []string{"es", "fr", "zh"} {
+		if got := keys(locale); !slices.Equal(got, want) {
+			t.Errorf("Locale %s has keys %v, want %v", locale, got, want)
+		}
+	}
+}
+
+func TestLoadCatalog_RequiresEnglish(t *testing.T) {
+	fsys := fstest.MapFS{"locales/es.json": {Data: []byte(`{"a": "b"}`)}}
+	if _, err := LoadCatalog(fsys, "locales"); err == nil {
+		t.Error("Expected an error without an English locale")
+	}
+}
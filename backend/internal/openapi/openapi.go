@@ -0,0 +1,481 @@
+// Package openapi builds the OpenAPI 3 document describing the HTTP API.
+//
+// Schemas are generated from the Go request and response structs, so fields
+// cannot drift from what the handlers encode and decode. Ranges and enum values
+// come from the validation package.
+package openapi
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+	"sync"
+
+	"ivf-calculator-backend/internal/batch"
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/i18n"
+	"ivf-calculator-backend/internal/validation"
+)
+
+// Version is the OpenAPI version of the document
+const Version = "3.0.3"
+
+// specs caches the documents built by Spec, keyed by the most retrievals
+var specs sync.Map
+
+// Spec returns the OpenAPI document for formulas estimating up to
+// maxRetrievals intended retrievals. It is built once per limit and must not
+// be modified.
+func Spec(maxRetrievals int) map[string]any {
+	if spec, ok := specs.Load(maxRetrievals); ok {
+		return spec.(map[string]any)
+	}
+	spec, _ := specs.LoadOrStore(maxRetrievals, build(validation.CalculateRequestSchema.WithMaxRetrievals(maxRetrievals)))
+	return spec.(map[string]any)
+}
+
+// schemaRef returns a reference to a component schema
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// build builds the document with the CalculateRequest constraints of schema
+func build(schema validation.Schema) map[string]any {
+	g := newGenerator()
+
+	calculateRequest := g.ref(reflect.TypeOf(calculator.CalculateRequest{}))
+	calculateResponse := g.ref(reflect.TypeOf(calculator.CalculateResponse{}))
+	versionInfo := g.ref(reflect.TypeOf(calculator.VersionInfo{}))
+	modelInfo := g.ref(reflect.TypeOf(calculator.ModelInfo{}))
+	compareOption := g.ref(reflect.TypeOf(calculator.CompareOption{}))
+	curveRequest := g.ref(reflect.TypeOf(calculator.CurveRequest{}))
+	curveResponse := g.ref(reflect.TypeOf(calculator.CurveResponse{}))
+	simulationRequest := g.ref(reflect.TypeOf(calculator.SimulationRequest{}))
+	simulationResponse := g.ref(reflect.TypeOf(calculator.SimulationResponse{}))
+	batchItem := g.ref(reflect.TypeOf(batch.Item{}))
+	batchResult := g.ref(reflect.TypeOf(batch.Result{}))
+	fieldError := g.ref(reflect.TypeOf(validation.FieldError{}))
+	validationSchema := g.ref(reflect.TypeOf(validation.Schema{}))
+
+	constraints := maps.Clone(fieldConstraints)
+	constraints["CalculateRequest"] = ruleConstraints(schema, calculateRequestDescriptions)
+	g.applyConstraints(constraints)
+
+	schemas := g.schemas
+	schemas["Health"] = object(map[string]any{
+		"status":          map[string]any{"type": "string", "enum": []string{"ok"}},
+		"modelVersion":    map[string]any{"type": "string"},
+		"formulaChecksum": map[string]any{"type": "string"},
+	}, "status", "modelVersion", "formulaChecksum")
+	schemas["ValidationErrors"] = object(map[string]any{
+		"errors": map[string]any{"type": "array", "items": fieldError},
+	}, "errors")
+	schemas["Error"] = object(map[string]any{
+		"error":   map[string]any{"type": "string"},
+		"details": map[string]any{"type": "string"},
+	}, "error")
+
+	return map[string]any{
+		"openapi": Version,
+		"info": map[string]any{
+			"title":       "IVF Calculator API",
+			"version":     "1.0.0",
+			"description": "Estimates the chance of a live birth from IVF using the CDC formulas.",
+		},
+		"paths": map[string]any{
+			"/healthz": map[string]any{
+				"get": operation("Health check with the active formula version",
+					nil, response("Server is up", schemaRef("Health"))),
+			},
+			"/api/calculate": map[string]any{
+				"post": withParameters(operation("Calculate the chance of a live birth",
+					calculateRequest, response("Calculated chance, with caveats of inputs the model is less reliable for", map[string]any{
+						"allOf": []any{calculateResponse, object(map[string]any{
+							"warnings": map[string]any{"type": "array", "items": fieldError},
+						})},
+					}), calculateErrors()),
+					map[string]any{
+						"name":        "explain",
+						"in":          "query",
+						"description": "Include the breakdown of every logit term",
+						"schema":      map[string]any{"type": "boolean"},
+					}, roundingParameter()),
+			},
+			"/api/calculate/reasons": map[string]any{
+				"get": operation("List the infertility reasons with localized display names",
+					nil, response("Reasons in the order the form shows them", object(map[string]any{
+						"locale": map[string]any{"type": "string"},
+						"reasons": map[string]any{"type": "array", "items": object(map[string]any{
+							"value": map[string]any{"type": "string", "enum": validation.Reasons},
+							"label": map[string]any{"type": "string"},
+						}, "value", "label")},
+					}, "locale", "reasons"))),
+			},
+			"/api/calculate/schema": map[string]any{
+				"get": operation("Describe the fields of a calculate request and the rules they are validated against",
+					nil, response("Validation rules with enum labels in the response's locale", map[string]any{
+						"allOf": []any{validationSchema, object(map[string]any{
+							"locale": map[string]any{"type": "string"},
+						}, "locale")},
+					})),
+			},
+			"/api/calculate/versions": map[string]any{
+				"get": operation("List the available formula versions",
+					nil, response("Formula versions, oldest first", object(map[string]any{
+						"versions": map[string]any{"type": "array", "items": versionInfo},
+					}, "versions"))),
+			},
+			"/api/calculate/compare": map[string]any{
+				"post": withParameters(operation("Calculate the chance with own eggs and with donor eggs side by side",
+					calculateRequest, response("One result per option: own eggs with the given prior IVF answer, or with both answers when it is omitted, then donor eggs", object(map[string]any{
+						"options": map[string]any{"type": "array", "items": map[string]any{
+							"allOf": []any{compareOption, object(map[string]any{
+								"warnings": map[string]any{"type": "array", "items": fieldError},
+							})},
+						}},
+					}, "options")), calculateErrors()), roundingParameter()),
+			},
+			"/api/calculate/models": map[string]any{
+				"get": operation("List the prediction models requests can select",
+					nil, response("Models sorted by name", object(map[string]any{
+						"models": map[string]any{"type": "array", "items": modelInfo},
+					}, "models"))),
+			},
+			"/api/calculate/curve": map[string]any{
+				"post": withParameters(operation("Calculate the chance over a range of ages, BMIs or weights",
+					curveRequest, response("Chance at every point of the range", curveResponse), calculateErrors()), roundingParameter()),
+			},
+			"/api/calculate/simulate": map[string]any{
+				"post": withParameters(operation("Simulate the spread of the chance under coefficient and measurement uncertainty",
+					simulationRequest, response("Summary of the simulated chances", simulationResponse), calculateErrors()), roundingParameter()),
+			},
+			"/api/calculate/batch": map[string]any{
+				"post": withParameters(operation("Calculate many patients at once",
+					map[string]any{"type": "array", "items": batchItem},
+					response("One result per item, in request order", object(map[string]any{
+						"results": map[string]any{"type": "array", "items": batchResult},
+					}, "results")),
+					map[string]any{
+						"400": response("The body is not a JSON array", schemaRef("ValidationErrors")),
+						"413": response("Too many items", schemaRef("Error")),
+					}), roundingParameter()),
+			},
+			"/api/calculate/batch/csv": map[string]any{
+				"post": map[string]any{
+					"summary": "Calculate every row of a CSV of patients",
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  map[string]any{"text/csv": map[string]any{"schema": map[string]any{"type": "string"}}},
+					},
+					"parameters": localeParameters(),
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The CSV with result columns appended",
+							"content":     map[string]any{"text/csv": map[string]any{"schema": map[string]any{"type": "string"}}},
+						},
+						"400": response("The CSV header is invalid", schemaRef("Error")),
+					},
+				},
+			},
+		},
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+// operation describes a JSON operation with an optional request body, its
+// success response and any error responses
+func operation(summary string, body map[string]any, success map[string]any, errors ...map[string]any) map[string]any {
+	responses := map[string]any{"200": success}
+	for _, e := range errors {
+		for status, r := range e {
+			responses[status] = r
+		}
+	}
+
+	op := map[string]any{"summary": summary, "responses": responses, "parameters": localeParameters()}
+	if body != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": body}},
+		}
+	}
+	return op
+}
+
+func withParameters(op map[string]any, parameters ...map[string]any) map[string]any {
+	op["parameters"] = append(op["parameters"].([]map[string]any), parameters...)
+	return op
+}
+
+// roundingParameter selects the rounding policy of requests that do not set one
+func roundingParameter() map[string]any {
+	return map[string]any{
+		"name":        "rounding",
+		"in":          "query",
+		"description": "Rounding policy of the percentages, for requests whose body does not set rounding",
+		"schema":      map[string]any{"type": "string", "enum": calculator.RoundingPolicies},
+	}
+}
+
+// localeParameters select the language of messages
+func localeParameters() []map[string]any {
+	locales := map[string]any{"type": "string", "enum": i18n.Default().Locales()}
+	return []map[string]any{
+		{"name": "locale", "in": "query", "description": "Language of messages, overriding Accept-Language", "schema": locales},
+		{"name": "Accept-Language", "in": "header", "description": "Preferred languages of messages; English by default", "schema": map[string]any{"type": "string"}},
+	}
+}
+
+func response(description string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schema}},
+	}
+}
+
+// calculateErrors are the error responses of the calculate endpoints
+func calculateErrors() map[string]any {
+	return map[string]any{
+		"400": response("The body is malformed or fails validation", schemaRef("ValidationErrors")),
+		"422": response("No formula applies to the given parameters", schemaRef("Error")),
+		"500": response("Formulas are unavailable", schemaRef("Error")),
+	}
+}
+
+func object(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// calculateRequestDescriptions describes the fields of CalculateRequest, whose
+// constraints come from its validation schema
+var calculateRequestDescriptions = map[string]string{
+	"priorIvfCycles":   "Whether IVF was attempted before; required when eggSource is own",
+	"priorPregnancies": "2 means 2 or more",
+	"priorBirths":      "2 means 2 or more; cannot exceed priorPregnancies",
+	"reasons":          "unexplained and unknown must be selected by themselves",
+	"retrievals":       "Defaults to 1; the maximum is the most retrievals the loaded formulas estimate",
+	"model":            "Prediction model to calculate with; defaults to the configured model, cdc unless changed",
+	"modelVersion":     "Formula version of the cdc model to calculate with; defaults to the latest",
+	"confidenceLevel":  "Level of confidenceInterval; defaults to the configured level, 0.95 unless changed",
+	"rounding":         "Rounding policy of the percentages; defaults to the rounding query parameter, then the configured policy, ceil unless changed",
+}
+
+// fieldConstraints adds validation rules to generated properties, keyed by
+// component name and JSON field name
+var fieldConstraints = map[string]map[string]map[string]any{
+	"CurveRequest": {
+		"dimension": {"enum": []string{calculator.CurveAge, calculator.CurveBMI, calculator.CurveWeight}},
+		"step":      {"exclusiveMinimum": true, "minimum": 0, "description": fmt.Sprintf("At most %d points per curve", calculator.MaxCurvePoints)},
+	},
+	"SimulationRequest": {
+		"draws":          {"minimum": 1, "maximum": calculator.MaxSimulationDraws},
+		"seed":           {"description": "Seed of the random draws; the same request and seed give the same result"},
+		"standardErrors": {"description": fmt.Sprintf("Standard errors of coefficients by breakdown term name, each 0-%d; defaults to the variances of the formula's covariance", calculator.MaxStandardError)},
+		"percentiles": {
+			"items":       map[string]any{"type": "number", "minimum": 0, "maximum": 100},
+			"description": fmt.Sprintf("Percentiles to report; defaults to %v", calculator.DefaultSimulationPercentiles),
+		},
+		"buckets": {"minimum": 1, "maximum": calculator.MaxSimulationBuckets, "default": calculator.DefaultSimulationBuckets},
+	},
+	"CurveResponse": {
+		"rounding": {"enum": calculator.RoundingPolicies},
+	},
+	"InputNoise": {
+		"weight": {"minimum": 0, "description": "Standard deviation of weightLbs or weightKg"},
+		"height": {"minimum": 0, "description": "Standard deviation of the height in inches or heightCm"},
+		"bmi":    {"minimum": 0, "description": "Standard deviation of bmi"},
+	},
+	"SimulationResponse": {
+		"rounding":                {"enum": calculator.RoundingPolicies, "description": "Rounding policy of the mean and percentiles; draws are not rounded"},
+		"cumulativeChancePercent": {"description": "Chance without perturbation, as returned by /api/calculate"},
+		"standardDeviation":       {"description": "Population standard deviation of the simulated chances, rounded half-even to 2 decimal places"},
+		"percentiles":             {"description": "Nearest-rank percentiles of the simulated chances"},
+		"histogram":               {"description": "Equal-width buckets from the lowest to the highest simulated chance; bounds are rounded half-even to 2 decimal places"},
+		"standardErrors":          {"description": "Standard errors the coefficients were perturbed with"},
+	},
+	"CalculateResponse": {
+		"probability":     {"description": "Unrounded chance, between 0 and 1"},
+		"rounding":        {"enum": calculator.RoundingPolicies, "description": "Rounding policy of the percentages"},
+		"breakdown":       {"description": "Only included with ?explain=true, for models with logit terms"},
+		"formulaChecksum": {"description": "Checksum of the formulas of the cdc model"},
+	},
+	"ConfidenceInterval": {
+		"available":    {"description": "Whether the formula has variance data; without it the bounds are omitted"},
+		"lowerPercent": {"description": "Lower bound of cumulativeChancePercent"},
+		"upperPercent": {"description": "Upper bound of cumulativeChancePercent"},
+		"reason":       {"description": "Why the interval is unavailable"},
+	},
+	"ModelInfo": {
+		"covariates": {"description": "Request fields the model uses"},
+		"default":    {"description": "Whether requests without a model use this one"},
+	},
+	"ValidationRule": {
+		"type":       {"enum": []string{validation.TypeInteger, validation.TypeNumber, validation.TypeString, validation.TypeStrings}},
+		"min":        {"description": "Inclusive minimum"},
+		"max":        {"description": "Inclusive maximum"},
+		"default":    {"description": "Value used when the field is omitted or 0"},
+		"enum":       {"description": "Accepted values; the accepted items for string[] fields"},
+		"unitSystem": {"description": "The rule only applies to requests using this unit system"},
+	},
+	"ValidationConstraint": {
+		"kind": {"enum": []string{
+			validation.ConstraintOneUnitSystem, validation.ConstraintRequiredIf,
+			validation.ConstraintNotAbove, validation.ConstraintExclusive,
+		}},
+		"default": {"description": "Unit system whose fields are reported as missing when none is used"},
+	},
+	"ValidationFieldError": {
+		"field": {"description": "JSON path of the field, empty for problems with the whole body"},
+		"code": {"enum": []string{
+			validation.CodeRequired, validation.CodeOutOfRange, validation.CodeInvalidValue, validation.CodeExclusive,
+			validation.CodeExceedsField, validation.CodeUnitSystem, validation.CodeNegative, validation.CodeInvalidRange,
+			validation.CodeTooManyPoints, validation.CodeFractional, validation.CodeInvalidType, validation.CodeInvalidJSON,
+			validation.WarningBMIBelowCalibration, validation.WarningBMIAboveCalibration, validation.WarningAgeOwnEggs,
+		}, "description": "Error code, or warning code in warnings"},
+		"params": {"description": "Values the message refers to, such as min and max for out_of_range"},
+	},
+}
+
+// ruleConstraints converts the rules of a validation schema to property
+// constraints, adding descriptions of the fields
+func ruleConstraints(schema validation.Schema, descriptions map[string]string) map[string]map[string]any {
+	fields := make(map[string]map[string]any)
+	for _, rule := range schema.Fields {
+		constraints := make(map[string]any)
+		if rule.Min != nil {
+			constraints["minimum"] = *rule.Min
+		}
+		if rule.Max != nil {
+			constraints["maximum"] = *rule.Max
+		}
+		if rule.Default != nil {
+			constraints["default"] = *rule.Default
+		}
+		if rule.Type == validation.TypeStrings {
+			constraints["items"] = map[string]any{"type": "string", "enum": rule.Enum}
+			constraints["minItems"] = rule.MinItems
+		} else if len(rule.Enum) > 0 {
+			constraints["enum"] = rule.Enum
+		}
+		fields[rule.Field] = constraints
+	}
+	for field, description := range descriptions {
+		if fields[field] == nil {
+			fields[field] = make(map[string]any)
+		}
+		fields[field]["description"] = description
+	}
+	return fields
+}
+
+// generator builds component schemas from Go types
+type generator struct {
+	schemas map[string]any
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: make(map[string]any)}
+}
+
+// componentName names the schema of a struct type; types outside the
+// calculator package are prefixed with their package name
+func componentName(t reflect.Type) string {
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	if pkg == "calculator" {
+		return t.Name()
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+}
+
+// ref registers the component schema for struct type t and returns a reference
+// to it. Embedded structs are composed with allOf so they keep their constraints.
+func (g *generator) ref(t reflect.Type) map[string]any {
+	component := componentName(t)
+	if _, ok := g.schemas[component]; ok {
+		return schemaRef(component)
+	}
+	g.schemas[component] = nil // reserve the name for recursive types
+
+	properties := make(map[string]any)
+	var required []string
+	var embedded []any
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, g.ref(field.Type))
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = g.schema(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			required = append(required, name)
+		}
+	}
+
+	schema := object(properties, required...)
+	if len(embedded) > 0 {
+		schema = map[string]any{"allOf": append(embedded, schema)}
+	}
+	g.schemas[component] = schema
+	return schemaRef(component)
+}
+
+// schema returns the schema of a field type
+func (g *generator) schema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Struct:
+		return g.ref(t)
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+// applyConstraints merges constraints into the generated schemas. A
+// constraint for a field that no longer exists is a programming error.
+func (g *generator) applyConstraints(constraints map[string]map[string]map[string]any) {
+	for component, fields := range constraints {
+		schema, ok := g.schemas[component].(map[string]any)
+		if !ok {
+			panic(fmt.Sprintf("openapi: constraints for unknown schema %s", component))
+		}
+		properties := schema["properties"].(map[string]any)
+		for field, constraints := range fields {
+			property, ok := properties[field].(map[string]any)
+			if !ok {
+				panic(fmt.Sprintf("openapi: constraints for unknown field %s.%s", component, field))
+			}
+			for key, value := range constraints {
+				property[key] = value
+			}
+		}
+	}
+}
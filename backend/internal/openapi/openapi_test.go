@@ -0,0 +1,264 @@
+package openapi
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/validation"
+)
+
+// specRetrievals is the most retrievals of the formulas the tested spec documents
+const specRetrievals = 3
+
+// component returns a component schema of the spec after a JSON round trip,
+// as clients see it
+func component(t *testing.T, name string) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(Spec(specRetrievals))
+	if err != nil {
+		t.Fatalf("Failed to encode spec: %v", err)
+	}
+	var spec map[string]any
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("Failed to decode spec: %v", err)
+	}
+	schema, ok := spec["components"].(map[string]any)["schemas"].(map[string]any)[name].(map[string]any)
+	if !ok {
+		t.Fatalf("Schema %s not found", name)
+	}
+	return schema
+}
+
+func TestSpec_Paths(t *testing.T) {
+	paths := Spec(specRetrievals)["paths"].(map[string]any)
+	for _, path := range []string{"/healthz", "/api/calculate", "/api/calculate/versions", "/api/calculate/models", "/api/calculate/reasons", "/api/calculate/schema", "/api/calculate/compare", "/api/calculate/curve", "/api/calculate/simulate", "/api/calculate/batch", "/api/calculate/batch/csv"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("Path %s not documented", path)
+		}
+	}
+}
+
+// TestSpec_SchemasMatchStructs checks every JSON field of the request and
+// response structs is documented, and nothing else
+func TestSpec_SchemasMatchStructs(t *testing.T) {
+	tests := []struct {
+		schema string
+		value  any
+	}{
+		{"CalculateRequest", calculator.CalculateRequest{}},
+		{"CalculateResponse", calculator.CalculateResponse{Breakdown: &calculator.Breakdown{}}},
+		{"Breakdown", calculator.Breakdown{}},
+		{"CompareOption", calculator.CompareOption{}},
+		{"CurveRequest", calculator.CurveRequest{}},
+		{"CurveResponse", calculator.CurveResponse{}},
+		{"SimulationRequest", calculator.SimulationRequest{}},
+		{"SimulationResponse", calculator.SimulationResponse{}},
+		{"VersionInfo", calculator.VersionInfo{}},
+		{"ModelInfo", calculator.ModelInfo{}},
+		{"ValidationFieldError", validation.FieldError{}},
+		{"ValidationSchema", validation.Schema{}},
+		{"ValidationRule", validation.Rule{}},
+		{"ValidationConstraint", validation.Constraint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			// Encode a value with every field set so omitempty fields are included
+			value := reflect.New(reflect.TypeOf(tt.value)).Elem()
+			value.Set(reflect.ValueOf(tt.value))
+			fill(value)
+			data, err := json.Marshal(value.Interface())
+			if err != nil {
+				t.Fatalf("Failed to encode %T: %v", tt.value, err)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("Failed to decode %T: %v", tt.value, err)
+			}
+
+			properties := properties(t, component(t, tt.schema))
+			for field := range fields {
+				if _, ok := properties[field]; !ok {
+					t.Errorf("Field %s is not documented", field)
+				}
+			}
+			for property := range properties {
+				if _, ok := fields[property]; !ok {
+					t.Errorf("Documented property %s is not a field", property)
+				}
+			}
+		})
+	}
+}
+
+// properties returns the properties of an object schema, including those of
+// the schemas it composes with allOf
+func properties(t *testing.T, schema map[string]any) map[string]any {
+	t.Helper()
+	all := make(map[string]any)
+	own, _ := schema["properties"].(map[string]any)
+	for name, property := range own {
+		all[name] = property
+	}
+	parts, _ := schema["allOf"].([]any)
+	for _, part := range parts {
+		part := part.(map[string]any)
+		if ref, ok := part["$ref"].(string); ok {
+			part = component(t, strings.TrimPrefix(ref, "#/components/schemas/"))
+		}
+		for name, property := range properties(t, part) {
+			all[name] = property
+		}
+	}
+	return all
+}
+
+// fill sets every zero field of v to a non-zero value
+func fill(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i))
+			}
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		fill(v.Elem())
+	case reflect.Slice:
+		if v.Len() == 0 {
+			v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		}
+	case reflect.Map:
+		if v.Len() == 0 {
+			v.Set(reflect.MakeMap(v.Type()))
+			v.SetMapIndex(reflect.ValueOf("x").Convert(v.Type().Key()), reflect.Zero(v.Type().Elem()))
+		}
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int:
+		v.SetInt(1)
+	case reflect.Float64:
+		v.SetFloat(1)
+	case reflect.String:
+		v.SetString("x")
+	}
+}
+
+// TestSpec_ConstraintsMatchValidation checks the documented ranges and enum
+// values are the ones ValidateCalculateRequest enforces
+func TestSpec_ConstraintsMatchValidation(t *testing.T) {
+	properties := component(t, "CalculateRequest")["properties"].(map[string]any)
+
+	for field, p := range properties {
+		property := p.(map[string]any)
+		min, hasMin := property["minimum"].(float64)
+		max, hasMax := property["maximum"].(float64)
+		if !hasMin || !hasMax {
+			continue
+		}
+
+		delta := 0.1
+		if property["type"] == "integer" {
+			delta = 1
+		}
+		for _, value := range []float64{min, max} {
+			if errors := validate(t, field, value).Field(field); len(errors) > 0 {
+				t.Errorf("%s = %g is documented as valid but fails: %v", field, value, errors)
+			}
+		}
+		// Binding rejects negative heightIn and priorBirths, and a retrievals of 0 means the default
+		if field != "heightIn" && field != "priorBirths" && field != "retrievals" {
+			if errors := validate(t, field, min-delta).Field(field); len(errors) == 0 {
+				t.Errorf("%s = %g is documented as invalid but passes", field, min-delta)
+			}
+		}
+		// priorBirths is limited by priorPregnancies
+		if field != "priorBirths" {
+			if errors := validate(t, field, max+delta).Field(field); len(errors) == 0 {
+				t.Errorf("%s = %g is documented as invalid but passes", field, max+delta)
+			}
+		}
+	}
+
+	eggSources := properties["eggSource"].(map[string]any)["enum"].([]any)
+	for _, source := range append(eggSources, "other") {
+		req := validRequest()
+		req.EggSource = source.(string)
+		errors := validation.ValidateCalculateRequest(req, specRetrievals)
+		if valid := len(errors.Field("eggSource")) == 0; valid != (source != "other") {
+			t.Errorf("eggSource %q: validation errors %v", source, errors)
+		}
+	}
+
+	reasons := properties["reasons"].(map[string]any)["items"].(map[string]any)["enum"].([]any)
+	for _, reason := range append(reasons, "other_reason") {
+		req := validRequest()
+		req.Reasons = []string{reason.(string)}
+		errors := validation.ValidateCalculateRequest(req, specRetrievals)
+		if valid := len(errors.Field("reasons")) == 0; valid != (reason != "other_reason") {
+			t.Errorf("reason %q: validation errors %v", reason, errors)
+		}
+	}
+}
+
+func validRequest() calculator.CalculateRequest {
+	return calculator.CalculateRequest{
+		Age:              32,
+		WeightLbs:        150,
+		HeightFt:         5,
+		HeightIn:         6,
+		EggSource:        "own",
+		PriorIvfCycles:   "no",
+		PriorPregnancies: 2,
+		Reasons:          []string{"unknown"},
+	}
+}
+
+// validate validates a valid request with the JSON field set to value, using
+// the field's unit system
+func validate(t *testing.T, field string, value float64) validation.Errors {
+	t.Helper()
+	req := validRequest()
+	switch field {
+	case "weightKg", "heightCm":
+		req.WeightLbs, req.HeightFt, req.HeightIn = 0, 0, 0
+		req.WeightKg, req.HeightCm = 68, 168
+	case "bmi":
+		req.WeightLbs, req.HeightFt, req.HeightIn = 0, 0, 0
+		req.BMI = 24
+	}
+
+	v := reflect.ValueOf(&req).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if name != field {
+			continue
+		}
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Int:
+			f.SetInt(int64(math.Round(value)))
+		case reflect.Float64:
+			f.SetFloat(value)
+		}
+		return validation.ValidateCalculateRequest(req, specRetrievals)
+	}
+	t.Fatalf("Field %s not found", field)
+	return nil
+}
+
+func TestSpec_RetrievalsOfFormulas(t *testing.T) {
+	for _, maxRetrievals := range []int{1, 3} {
+		schema := Spec(maxRetrievals)["components"].(map[string]any)["schemas"].(map[string]any)["CalculateRequest"].(map[string]any)
+		retrievals := schema["properties"].(map[string]any)["retrievals"].(map[string]any)
+		if retrievals["maximum"] != float64(maxRetrievals) {
+			t.Errorf("Expected a maximum of %d retrievals, got %v", maxRetrievals, retrievals["maximum"])
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// BindingErrors converts an error from decoding or binding a request body into
+// target to validation errors, so malformed requests are reported in the same
+// shape as invalid ones. target is the struct the body was bound to.
+func BindingErrors(err error, target any) Errors {
+	var result Errors
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fe := range validationErrs {
+			field := jsonPath(reflect.TypeOf(target), fe.StructNamespace())
+			switch fe.Tag() {
+			case "required":
+				result.add(field, CodeRequired, nil)
+			case "gte", "min":
+				var min any = fe.Param()
+				if n, err := strconv.Atoi(fe.Param()); err == nil {
+					min = n
+				}
+				result.add(field, CodeOutOfRange, map[string]any{"min": min})
+			default:
+				result.add(field, CodeInvalidValue, map[string]any{"rule": fe.Tag()})
+			}
+		}
+	case errors.As(err, &typeErr):
+		result.add(typeErr.Field, CodeInvalidType, map[string]any{"expected": jsonType(typeErr.Type), "got": typeErr.Value})
+	default:
+		result.add("", CodeInvalidJSON, map[string]any{"detail": err.Error()})
+	}
+
+	return result
+}
+
+// jsonPath converts a validator struct namespace such as
+// "CurveRequest.Base.HeightIn" to the JSON path "base.heightIn"
+func jsonPath(t reflect.Type, namespace string) string {
+	parts := strings.Split(namespace, ".")
+	var path []string
+	for _, part := range parts[1:] {
+		name, index, _ := strings.Cut(part, "[")
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+
+		field, ok := t.FieldByName(name)
+		if !ok || t.Kind() != reflect.Struct {
+			path = append(path, part)
+			continue
+		}
+		t = field.Type
+
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && jsonName == "" {
+			continue // embedded struct fields are promoted
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+		if index != "" {
+			jsonName += "[" + index
+		}
+		path = append(path, jsonName)
+	}
+	return strings.Join(path, ".")
+}
+
+// jsonType names a Go type as the JSON type it is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
@@ -0,0 +1,69 @@
+package validation
+
+import (
+	"ivf-calculator-backend/internal/calculator"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestBindingErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		target   func() any
+		wantErrs Errors
+	}{
+		{
+			name:   "missing required fields",
+			body:   `{"bmi": 22.8, "heightIn": -1}`,
+			target: func() any { return &calculator.CalculateRequest{} },
+			wantErrs: Errors{
+				{"age", CodeRequired, "is required", nil},
+				{"heightIn", CodeOutOfRange, "must be at least 0", map[string]any{"min": 0}},
+				{"reasons", CodeRequired, "at least one reason must be selected", nil},
+				{"eggSource", CodeRequired, "is required", nil},
+			},
+		},
+		{
+			name:   "nested field",
+			body:   `{"base": {"age": 30, "bmi": 22.8, "eggSource": "donor"}, "dimension": "age", "step": 1}`,
+			target: func() any { return &calculator.CurveRequest{} },
+			wantErrs: Errors{
+				{"base.reasons", CodeRequired, "at least one reason must be selected", nil},
+			},
+		},
+		{
+			name:   "wrong type",
+			body:   `{"age": "thirty"}`,
+			target: func() any { return &calculator.CalculateRequest{} },
+			wantErrs: Errors{
+				{"age", CodeInvalidType, "must be of type integer", map[string]any{"expected": "integer", "got": "string"}},
+			},
+		},
+		{
+			name:   "malformed JSON",
+			body:   `{"age": `,
+			target: func() any { return &calculator.CalculateRequest{} },
+			wantErrs: Errors{
+				{"", CodeInvalidJSON, "invalid request format: unexpected EOF", map[string]any{"detail": "unexpected EOF"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target()
+			err := binding.JSON.BindBody([]byte(tt.body), target)
+			if err == nil {
+				t.Fatal("Expected a binding error")
+			}
+
+			gotErrs := BindingErrors(err, reflect.ValueOf(target).Elem().Interface())
+			if !reflect.DeepEqual(gotErrs, tt.wantErrs) {
+				t.Errorf("BindingErrors() = %+v, want %+v", []FieldError(gotErrs), []FieldError(tt.wantErrs))
+			}
+		})
+	}
+}
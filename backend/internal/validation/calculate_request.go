@@ -2,83 +2,76 @@ package validation
 
 import (
 	"ivf-calculator-backend/internal/calculator"
-	"slices"
+	"math"
 )
 
-// ValidateCalculateRequest validates the calculate request and returns errors if any
-func ValidateCalculateRequest(req calculator.CalculateRequest) map[string]string {
-	errors := make(map[string]string)
-
-	if req.Age < 20 || req.Age > 50 {
-		errors["age"] = "must be between 20 and 50"
-	}
-
-	if req.WeightLbs < 80 || req.WeightLbs > 300 {
-		errors["weightLbs"] = "must be between 80 and 300"
-	}
-
-	if req.HeightFt < 4 || req.HeightFt > 6 {
-		errors["heightFt"] = "must be between 4 and 7"
-	}
-
-	if req.HeightIn < 0 || req.HeightIn > 11 {
-		errors["heightIn"] = "must be between 0 and 12"
+// Accepted values of the enumerated request fields
+var (
+	EggSources            = []string{"own", "donor"}
+	PriorIvfCyclesOptions = []string{"yes", "no"}
+	Reasons               = []string{
+		"male_factor_infertility",
+		"endometriosis",
+		"tubal_factor",
+		"ovulatory_disorder",
+		"diminished_ovarian_reserve",
+		"uterine_factor",
+		"other",
+		"unexplained",
+		"unknown",
 	}
+)
 
+// Inclusive limits of the whole-number request fields
+const (
+	MinAge              = 20
+	MaxAge              = 50
+	MinWeightLbs        = 80
+	MaxWeightLbs        = 300
+	MinHeightFt         = 4
+	MaxHeightFt         = 6
+	MinHeightIn         = 0
+	MaxHeightIn         = 11
+	MinRetrievals       = 1
+	MaxPriorPregnancies = 2
+)
 
-	if req.EggSource != "own" && req.EggSource != "donor" {
-		errors["eggSource"] = "must be 'own' or 'donor'"
-	}
-
-	if req.EggSource == "own" && req.PriorIvfCycles == "" {
-		errors["priorIvfCycles"] = "must be 'yes' or 'no' when planning to use 'own' eggs"
-	}
-
-	validatePregnanciesBirths(req, errors)
-	validateReasons(req, errors)
-
-	return errors
-}
-
-func validatePregnanciesBirths(req calculator.CalculateRequest, errors map[string]string) {
-	if req.PriorPregnancies < 0 || req.PriorPregnancies > 2 {
-		errors["priorPregnancies"] = "must be 0, 1, or 2+"
-	}
+// Inclusive limits of the requested confidence level
+const (
+	MinConfidenceLevel = 0.5
+	MaxConfidenceLevel = 0.999
+)
 
-	if req.PriorBirths > req.PriorPregnancies {
-		errors["priorBirths"] += "cannot exceed the number of prior pregnancies (even in the case of twins)"
-	}
+// ValidateCalculateRequest validates the calculate request against
+// CalculateRequestSchema, accepting up to maxRetrievals intended retrievals,
+// and returns errors if any
+func ValidateCalculateRequest(req calculator.CalculateRequest, maxRetrievals int) Errors {
+	return CalculateRequestSchema.WithMaxRetrievals(maxRetrievals).validate(req)
 }
 
-func validateReasons(req calculator.CalculateRequest, errors map[string]string) {
-	if len(req.Reasons) == 0 {
-		errors["reasons"] = "at least one reason must be selected"
-	}
-
-	if slices.Contains(req.Reasons, "unexplained") && len(req.Reasons) != 1 {
-		errors["reasons"] = "'Unexplained (Idiopathic) infertility' must be selected by itself"
-	}
+// Metric and BMI limits are the imperial limits converted, widened to one
+// decimal place so the boundaries accept the same people
+var (
+	MinWeightKg = floorTenth(MinWeightLbs * kgPerLb)
+	MaxWeightKg = ceilTenth(MaxWeightLbs * kgPerLb)
+	MinHeightCm = floorTenth(MinHeightFt * 12 * cmPerIn)
+	MaxHeightCm = ceilTenth((MaxHeightFt*12 + MaxHeightIn) * cmPerIn)
+	MinBMI      = floorTenth(float64(MinWeightLbs) / ((MaxHeightFt*12 + MaxHeightIn) * (MaxHeightFt*12 + MaxHeightIn)) * 703)
+	MaxBMI      = ceilTenth(float64(MaxWeightLbs) / (MinHeightFt * 12 * MinHeightFt * 12) * 703)
+)
 
-	if slices.Contains(req.Reasons, "unknown") && len(req.Reasons) != 1 {
-		errors["reasons"] = "'I don't know/no reason' must be selected by itself"
-	}
+const (
+	kgPerLb = 0.45359237
+	cmPerIn = 2.54
+)
 
-	validReasons := map[string]bool{
-		"male_factor_infertility":  true,
-		"endometriosis":            true,
-		"tubal_factor":             true,
-		"ovulatory_disorder":       true,
-		"diminished_ovarian_reserve": true,
-		"uterine_factor":           true,
-		"other":                    true,
-		"unexplained":              true,
-		"unknown":                  true,
-	}
+func floorTenth(v float64) float64 { return math.Floor(v*10) / 10 }
+func ceilTenth(v float64) float64  { return math.Ceil(v*10) / 10 }
 
-	for _, reason := range req.Reasons {
-		if !validReasons[reason] {
-			errors["reasons"] = "invalid reason: " + reason
-			break
-		}
-	}
+// ValidateCompareRequest validates a request to compare egg sources. eggSource
+// is ignored, and the rest is validated as for donor eggs, whose rules every
+// option shares: priorIvfCycles is optional but must be valid when given.
+func ValidateCompareRequest(req calculator.CalculateRequest, maxRetrievals int) Errors {
+	req.EggSource = "donor"
+	return ValidateCalculateRequest(req, maxRetrievals)
 }
@@ -6,11 +6,14 @@ import (
 	"testing"
 )
 
+// formulaRetrievals is the most retrievals of formula files with retrieval columns
+const formulaRetrievals = 3
+
 func TestValidateCalculateRequest(t *testing.T) {
 	tests := []struct {
 		name     string
 		req      calculator.CalculateRequest
-		wantErrs map[string]string
+		wantErrs Errors
 	}{
 		{
 			name: "valid request",
@@ -25,23 +28,23 @@ func TestValidateCalculateRequest(t *testing.T) {
 				PriorBirths:      1,
 				Reasons:          []string{"male_factor_infertility"},
 			},
-			wantErrs: map[string]string{},
+			wantErrs: nil,
 		},
 		{
 			name: "invalid age, weight, height, and heightIn",
 			req: calculator.CalculateRequest{
-				Age:      19,
+				Age:       19,
 				WeightLbs: 301,
 				HeightFt:  3,
 				HeightIn:  12,
 			},
-			wantErrs: map[string]string{
-				"age":      "must be between 20 and 50",
-				"weightLbs": "must be between 80 and 300",
-				"heightFt":  "must be between 4 and 7",
-				"heightIn":  "must be between 0 and 12",
-				"eggSource": "must be 'own' or 'donor'",
-				"reasons":   "at least one reason must be selected",
+			wantErrs: Errors{
+				{"age", CodeOutOfRange, "must be between 20 and 50", map[string]any{"min": 20, "max": 50}},
+				{"weightLbs", CodeOutOfRange, "must be between 80 and 300", map[string]any{"min": 80, "max": 300}},
+				{"heightFt", CodeOutOfRange, "must be between 4 and 6", map[string]any{"min": 4, "max": 6}},
+				{"heightIn", CodeOutOfRange, "must be between 0 and 11", map[string]any{"min": 0, "max": 11}},
+				{"eggSource", CodeInvalidValue, "must be 'own' or 'donor'", map[string]any{"allowed": EggSources}},
+				{"reasons", CodeRequired, "at least one reason must be selected", nil},
 			},
 		},
 		{
@@ -54,115 +57,301 @@ func TestValidateCalculateRequest(t *testing.T) {
 				EggSource: "own",
 				Reasons:   []string{"other"},
 			},
-			wantErrs: map[string]string{
-				"priorIvfCycles": "must be 'yes' or 'no' when planning to use 'own' eggs",
+			wantErrs: Errors{
+				{"priorIvfCycles", CodeRequired, "must be 'yes' or 'no' when planning to use 'own' eggs",
+					map[string]any{"allowed": PriorIvfCyclesOptions, "eggSource": "own"}},
 			},
 		},
 		{
 			name: "invalid pregnancies and births relationship",
 			req: calculator.CalculateRequest{
-				Age:       35,
-				WeightLbs: 140,
-				HeightFt:  5,
-				HeightIn:  5,
-				EggSource: "donor",
+				Age:              35,
+				WeightLbs:        140,
+				HeightFt:         5,
+				HeightIn:         5,
+				EggSource:        "donor",
 				PriorPregnancies: 1,
 				PriorBirths:      2,
 				Reasons:          []string{"other"},
 			},
-			wantErrs: map[string]string{
-				"priorBirths": "cannot exceed the number of prior pregnancies (even in the case of twins)",
+			wantErrs: Errors{
+				{"priorBirths", CodeExceedsField, "cannot exceed the number of prior pregnancies (even in the case of twins)",
+					map[string]any{"field": "priorPregnancies"}},
 			},
 		},
 		{
 			name: "invalid reasons",
 			req: calculator.CalculateRequest{
-				Age:       35,
-				WeightLbs: 140,
-				HeightFt:  5,
-				HeightIn:  5,
-				EggSource: "donor",
+				Age:              35,
+				WeightLbs:        140,
+				HeightFt:         5,
+				HeightIn:         5,
+				EggSource:        "donor",
 				PriorPregnancies: 0,
 				PriorBirths:      0,
-				Reasons: []string{"invalid_reason"},
+				Reasons:          []string{"invalid_reason"},
 			},
-			wantErrs: map[string]string{
-				"reasons": "invalid reason: invalid_reason",
+			wantErrs: Errors{
+				{"reasons", CodeInvalidValue, "invalid reason: invalid_reason", map[string]any{"value": "invalid_reason", "allowed": Reasons}},
 			},
 		},
 		{
 			name: "unexplained reason must be alone",
 			req: calculator.CalculateRequest{
-				Age:       35,
-				WeightLbs: 140,
-				HeightFt:  5,
-				HeightIn:  5,
-				EggSource: "donor",
+				Age:              35,
+				WeightLbs:        140,
+				HeightFt:         5,
+				HeightIn:         5,
+				EggSource:        "donor",
 				PriorPregnancies: 0,
 				PriorBirths:      0,
-				Reasons: []string{"unexplained", "male_factor_infertility"},
+				Reasons:          []string{"unexplained", "male_factor_infertility"},
 			},
-			wantErrs: map[string]string{
-				"reasons": "'Unexplained (Idiopathic) infertility' must be selected by itself",
+			wantErrs: Errors{
+				{"reasons", CodeExclusive, "'Unexplained (Idiopathic) infertility' must be selected by itself", map[string]any{"value": "unexplained"}},
 			},
 		},
 		{
 			name: "unknown reason must be alone",
 			req: calculator.CalculateRequest{
-				Age:       35,
-				WeightLbs: 140,
-				HeightFt:  5,
-				HeightIn:  5,
-				EggSource: "donor",
+				Age:              35,
+				WeightLbs:        140,
+				HeightFt:         5,
+				HeightIn:         5,
+				EggSource:        "donor",
 				PriorPregnancies: 0,
 				PriorBirths:      0,
-				Reasons: []string{"unknown", "endometriosis"},
+				Reasons:          []string{"unknown", "endometriosis"},
 			},
-			wantErrs: map[string]string{
-				"reasons": "'I don't know/no reason' must be selected by itself",
+			wantErrs: Errors{
+				{"reasons", CodeExclusive, "'I don't know/no reason' must be selected by itself", map[string]any{"value": "unknown"}},
 			},
 		},
 		{
-			name: "heightFt upper boundary",
+			name: "several reason problems are all reported",
 			req: calculator.CalculateRequest{
 				Age:       35,
-				WeightLbs: 140,
-				HeightFt:  7,
-				HeightIn:  5,
+				BMI:       22.8,
 				EggSource: "donor",
+				Reasons:   []string{"unexplained", "unknown", "bogus"},
+			},
+			wantErrs: Errors{
+				{"reasons", CodeInvalidValue, "invalid reason: bogus", map[string]any{"value": "bogus", "allowed": Reasons}},
+				{"reasons", CodeExclusive, "'Unexplained (Idiopathic) infertility' must be selected by itself", map[string]any{"value": "unexplained"}},
+				{"reasons", CodeExclusive, "'I don't know/no reason' must be selected by itself", map[string]any{"value": "unknown"}},
+			},
+		},
+		{
+			name: "heightFt upper boundary",
+			req: calculator.CalculateRequest{
+				Age:              35,
+				WeightLbs:        140,
+				HeightFt:         7,
+				HeightIn:         5,
+				EggSource:        "donor",
 				PriorPregnancies: 0,
 				PriorBirths:      0,
-				Reasons:  []string{"other"},
+				Reasons:          []string{"other"},
 			},
-			wantErrs: map[string]string{
-				"heightFt": "must be between 4 and 7",
+			wantErrs: Errors{
+				{"heightFt", CodeOutOfRange, "must be between 4 and 6", map[string]any{"min": 4, "max": 6}},
 			},
 		},
 		{
-			name: "empty reasons",
+			name: "too many retrievals",
+			req: calculator.CalculateRequest{
+				Age:              35,
+				WeightLbs:        140,
+				HeightFt:         5,
+				HeightIn:         5,
+				EggSource:        "donor",
+				PriorPregnancies: 0,
+				PriorBirths:      0,
+				Reasons:          []string{"other"},
+				Retrievals:       4,
+			},
+			wantErrs: Errors{
+				{"retrievals", CodeOutOfRange, "must be between 1 and 3, the most retrievals the loaded formulas can estimate", map[string]any{"min": 1, "max": 3}},
+			},
+		},
+		{
+			name: "valid metric request",
+			req: calculator.CalculateRequest{
+				Age:       35,
+				WeightKg:  63.5,
+				HeightCm:  165.1,
+				EggSource: "donor",
+				Reasons:   []string{"other"},
+			},
+			wantErrs: nil,
+		},
+		{
+			name: "metric out of range",
+			req: calculator.CalculateRequest{
+				Age:       35,
+				WeightKg:  30,
+				HeightCm:  220,
+				EggSource: "donor",
+				Reasons:   []string{"other"},
+			},
+			wantErrs: Errors{
+				{"weightKg", CodeOutOfRange, "must be between 36.2 and 136.1", map[string]any{"min": 36.2, "max": 136.1}},
+				{"heightCm", CodeOutOfRange, "must be between 121.9 and 210.9", map[string]any{"min": 121.9, "max": 210.9}},
+			},
+		},
+		{
+			name: "valid bmi request",
+			req: calculator.CalculateRequest{
+				Age:       35,
+				BMI:       22.8,
+				EggSource: "donor",
+				Reasons:   []string{"other"},
+			},
+			wantErrs: nil,
+		},
+		{
+			name: "mixed unit systems",
 			req: calculator.CalculateRequest{
 				Age:       35,
 				WeightLbs: 140,
 				HeightFt:  5,
 				HeightIn:  5,
+				BMI:       95,
+				EggSource: "donor",
+				Reasons:   []string{"other"},
+			},
+			wantErrs: Errors{
+				{"bmi", CodeOutOfRange, "must be between 8.1 and 91.6", map[string]any{"min": 8.1, "max": 91.6}},
+				{"units", CodeUnitSystem, "provide exactly one of weightLbs/heightFt/heightIn, weightKg/heightCm, or bmi",
+					map[string]any{"given": []string{calculator.UnitsImperial, calculator.UnitsBMI}}},
+			},
+		},
+		{
+			name: "no body measurements",
+			req: calculator.CalculateRequest{
+				Age:       35,
 				EggSource: "donor",
+				Reasons:   []string{"other"},
+			},
+			wantErrs: Errors{
+				{"weightLbs", CodeOutOfRange, "must be between 80 and 300", map[string]any{"min": 80, "max": 300}},
+				{"heightFt", CodeOutOfRange, "must be between 4 and 6", map[string]any{"min": 4, "max": 6}},
+				{"units", CodeUnitSystem, "provide exactly one of weightLbs/heightFt/heightIn, weightKg/heightCm, or bmi",
+					map[string]any{"given": []string{}}},
+			},
+		},
+		{
+			name: "empty reasons",
+			req: calculator.CalculateRequest{
+				Age:              35,
+				WeightLbs:        140,
+				HeightFt:         5,
+				HeightIn:         5,
+				EggSource:        "donor",
 				PriorPregnancies: 0,
 				PriorBirths:      0,
-				Reasons: []string{},
+				Reasons:          []string{},
+			},
+			wantErrs: Errors{
+				{"reasons", CodeRequired, "at least one reason must be selected", nil},
+			},
+		},
+		{
+			name: "unknown prior IVF cycles answer",
+			req: calculator.CalculateRequest{
+				Age:            35,
+				BMI:            22.8,
+				EggSource:      "own",
+				PriorIvfCycles: "maybe",
+				Reasons:        []string{"other"},
+			},
+			wantErrs: Errors{
+				{"priorIvfCycles", CodeInvalidValue, "must be 'yes' or 'no'", map[string]any{"allowed": PriorIvfCyclesOptions}},
 			},
-			wantErrs: map[string]string{
-				"reasons": "at least one reason must be selected",
+		},
+		{
+			name: "unknown rounding policy",
+			req: calculator.CalculateRequest{
+				Age:       35,
+				BMI:       22.8,
+				EggSource: "donor",
+				Reasons:   []string{"other"},
+				Rounding:  "nearest",
+			},
+			wantErrs: Errors{
+				{"rounding", CodeInvalidValue, "must be 'ceil', 'half_even', 'floor', 'integer' or 'raw'", map[string]any{"allowed": calculator.RoundingPolicies}},
+			},
+		},
+		{
+			name: "negative prior births",
+			req: calculator.CalculateRequest{
+				Age:         35,
+				BMI:         22.8,
+				EggSource:   "donor",
+				PriorBirths: -1,
+				Reasons:     []string{"other"},
+			},
+			wantErrs: Errors{
+				{"priorBirths", CodeOutOfRange, "must be between 0 and 2", map[string]any{"min": 0, "max": 2}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErrs := ValidateCalculateRequest(tt.req, formulaRetrievals)
+
+			if !reflect.DeepEqual(gotErrs, tt.wantErrs) {
+				t.Errorf("ValidateCalculateRequest() = %+v, want %+v", []FieldError(gotErrs), []FieldError(tt.wantErrs))
+			}
+		})
+	}
+}
+
+func TestValidateCalculateRequest_FormulaRetrievals(t *testing.T) {
+	req := calculator.CalculateRequest{Age: 35, BMI: 22.8, EggSource: "donor", Reasons: []string{"other"}, Retrievals: 2}
+
+	want := Errors{{"retrievals", CodeOutOfRange, "must be between 1 and 1, the most retrievals the loaded formulas can estimate", map[string]any{"min": 1, "max": 1}}}
+	if gotErrs := ValidateCalculateRequest(req, 1); !reflect.DeepEqual(gotErrs, want) {
+		t.Errorf("ValidateCalculateRequest() = %+v, want %+v", []FieldError(gotErrs), []FieldError(want))
+	}
+	if gotErrs := ValidateCalculateRequest(req, 2); gotErrs != nil {
+		t.Errorf("ValidateCalculateRequest() = %+v, want none", []FieldError(gotErrs))
+	}
+}
+
+func TestValidateCompareRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      calculator.CalculateRequest
+		wantErrs Errors
+	}{
+		{
+			name:     "no egg source or prior IVF answer",
+			req:      calculator.CalculateRequest{Age: 35, BMI: 22.8, Reasons: []string{"other"}},
+			wantErrs: nil,
+		},
+		{
+			name:     "egg source is ignored",
+			req:      calculator.CalculateRequest{Age: 35, BMI: 22.8, EggSource: "frozen", Reasons: []string{"other"}},
+			wantErrs: nil,
+		},
+		{
+			name: "invalid prior IVF answer",
+			req:  calculator.CalculateRequest{Age: 35, BMI: 22.8, PriorIvfCycles: "maybe", Reasons: []string{"other"}},
+			wantErrs: Errors{
+				{"priorIvfCycles", CodeInvalidValue, "must be 'yes' or 'no'", map[string]any{"allowed": PriorIvfCyclesOptions}},
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotErrs := ValidateCalculateRequest(tt.req)
+			gotErrs := ValidateCompareRequest(tt.req, formulaRetrievals)
 
 			if !reflect.DeepEqual(gotErrs, tt.wantErrs) {
-				t.Errorf("ValidateCalculateRequest() = %v, want %v", gotErrs, tt.wantErrs)
+				t.Errorf("ValidateCompareRequest() = %+v, want %+v", []FieldError(gotErrs), []FieldError(tt.wantErrs))
 			}
 		})
 	}
-}
\ No newline at end of file
+}
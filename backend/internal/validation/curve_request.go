@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"ivf-calculator-backend/internal/calculator"
+	"maps"
+	"math"
+	"slices"
+)
+
+// ValidateCurveRequest validates the curve request and returns errors if any.
+// Errors in the base patient are prefixed with "base.", and the first point
+// of the curve that falls outside the allowed ranges is reported as "range".
+func ValidateCurveRequest(req calculator.CurveRequest, maxRetrievals int) Errors {
+	var errors Errors
+
+	for _, fe := range ValidateCalculateRequest(req.Base, maxRetrievals) {
+		fe.Field = "base." + fe.Field
+		errors = append(errors, fe)
+	}
+
+	switch req.Dimension {
+	case calculator.CurveAge, calculator.CurveBMI, calculator.CurveWeight:
+	default:
+		errors.add("dimension", CodeInvalidValue, map[string]any{"allowed": []string{calculator.CurveAge, calculator.CurveBMI, calculator.CurveWeight}})
+	}
+
+	if req.Step <= 0 {
+		errors.add("step", CodeOutOfRange, map[string]any{"exclusiveMin": 0})
+	}
+
+	if req.To < req.From {
+		errors.add("to", CodeInvalidRange, map[string]any{"field": "from"})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	systems := req.Base.UnitSystems()
+	values, err := req.Values()
+	if err != nil {
+		errors.add("step", CodeTooManyPoints, map[string]any{"max": calculator.MaxCurvePoints})
+		return errors
+	}
+
+	// Whole-number fields can only be varied in whole steps from a whole number
+	if req.Dimension == calculator.CurveAge || (req.Dimension == calculator.CurveWeight && slices.Contains(systems, calculator.UnitsImperial)) {
+		for _, field := range []struct {
+			name  string
+			value float64
+		}{{"from", req.From}, {"step", req.Step}} {
+			if field.value != math.Trunc(field.value) {
+				errors.add(field.name, CodeFractional, map[string]any{"dimension": req.Dimension})
+			}
+		}
+	}
+	if req.Dimension == calculator.CurveWeight && slices.Contains(systems, calculator.UnitsBMI) {
+		errors.add("dimension", CodeUnitSystem, map[string]any{"given": systems})
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+
+	reqs, err := req.Requests()
+	if err != nil {
+		// The checks above cover every point Requests cannot produce
+		errors.add("range", CodeInvalidValue, nil)
+		return errors
+	}
+
+	for i, pointReq := range reqs {
+		pointErrors := ValidateCalculateRequest(pointReq, maxRetrievals)
+		if len(pointErrors) == 0 {
+			continue
+		}
+
+		// Report the first problem at the point, with the point in its params
+		first := pointErrors[0]
+		params := maps.Clone(first.Params)
+		if params == nil {
+			params = make(map[string]any)
+		}
+		params["dimension"] = req.Dimension
+		params["value"] = values[i]
+		params["field"] = first.Field
+		errors.add("range", first.Code, params)
+		break
+	}
+
+	return errors
+}
@@ -0,0 +1,116 @@
+package validation
+
+import (
+	"ivf-calculator-backend/internal/calculator"
+	"reflect"
+	"testing"
+)
+
+func TestValidateCurveRequest(t *testing.T) {
+	base := calculator.CalculateRequest{
+		Age:              30,
+		WeightLbs:        150,
+		HeightFt:         5,
+		HeightIn:         6,
+		EggSource:        "own",
+		PriorIvfCycles:   "no",
+		PriorPregnancies: 1,
+		PriorBirths:      1,
+		Reasons:          []string{"male_factor_infertility"},
+	}
+
+	tests := []struct {
+		name     string
+		req      calculator.CurveRequest
+		wantErrs Errors
+	}{
+		{
+			name:     "valid age curve",
+			req:      calculator.CurveRequest{Base: base, Dimension: "age", From: 30, To: 40, Step: 1},
+			wantErrs: nil,
+		},
+		{
+			name: "age curve past the allowed range",
+			req:  calculator.CurveRequest{Base: base, Dimension: "age", From: 40, To: 55, Step: 5},
+			wantErrs: Errors{
+				{"range", CodeOutOfRange, "at age 55: age must be between 20 and 50",
+					map[string]any{"min": 20, "max": 50, "dimension": "age", "value": 55.0, "field": "age"}},
+			},
+		},
+		{
+			name: "invalid dimension and step",
+			req:  calculator.CurveRequest{Base: base, Dimension: "height", From: 30, To: 40},
+			wantErrs: Errors{
+				{"dimension", CodeInvalidValue, "must be 'age', 'bmi' or 'weight'", map[string]any{"allowed": []string{"age", "bmi", "weight"}}},
+				{"step", CodeOutOfRange, "must be greater than 0", map[string]any{"exclusiveMin": 0}},
+			},
+		},
+		{
+			name: "invalid base request",
+			req: calculator.CurveRequest{
+				Base:      calculator.CalculateRequest{Age: 30, BMI: 22, EggSource: "donor"},
+				Dimension: "bmi", From: 20, To: 25, Step: 1,
+			},
+			wantErrs: Errors{
+				{"base.reasons", CodeRequired, "at least one reason must be selected", nil},
+			},
+		},
+		{
+			name: "fractional age step",
+			req:  calculator.CurveRequest{Base: base, Dimension: "age", From: 30, To: 35, Step: 0.5},
+			wantErrs: Errors{
+				{"step", CodeFractional, "must be a whole number for age", map[string]any{"dimension": "age"}},
+			},
+		},
+		{
+			name: "fractional weight start",
+			req:  calculator.CurveRequest{Base: base, Dimension: "weight", From: 140.5, To: 150, Step: 1},
+			wantErrs: Errors{
+				{"from", CodeFractional, "must be a whole number for weight", map[string]any{"dimension": "weight"}},
+			},
+		},
+		{
+			name: "fractional metric weight",
+			req: calculator.CurveRequest{
+				Base:      calculator.CalculateRequest{Age: 30, WeightKg: 65, HeightCm: 168, EggSource: "donor", Reasons: []string{"unknown"}},
+				Dimension: "weight", From: 60.5, To: 62, Step: 0.5,
+			},
+			wantErrs: nil,
+		},
+		{
+			name: "weight of a BMI request",
+			req: calculator.CurveRequest{
+				Base:      calculator.CalculateRequest{Age: 30, BMI: 22, EggSource: "donor", Reasons: []string{"unknown"}},
+				Dimension: "weight", From: 140, To: 150, Step: 5,
+			},
+			wantErrs: Errors{
+				{"dimension", CodeUnitSystem, "weight can only be varied for imperial or metric requests",
+					map[string]any{"given": []string{"bmi"}}},
+			},
+		},
+		{
+			name: "too many points",
+			req:  calculator.CurveRequest{Base: base, Dimension: "bmi", From: 10, To: 90, Step: 0.1},
+			wantErrs: Errors{
+				{"step", CodeTooManyPoints, "must produce at most 200 points", map[string]any{"max": 200}},
+			},
+		},
+		{
+			name: "step too small to count",
+			req:  calculator.CurveRequest{Base: base, Dimension: "bmi", From: 20, To: 40, Step: 1e-300},
+			wantErrs: Errors{
+				{"step", CodeTooManyPoints, "must produce at most 200 points", map[string]any{"max": 200}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErrs := ValidateCurveRequest(tt.req, formulaRetrievals)
+
+			if !reflect.DeepEqual(gotErrs, tt.wantErrs) {
+				t.Errorf("ValidateCurveRequest() = %+v, want %+v", []FieldError(gotErrs), []FieldError(tt.wantErrs))
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package validation
+
+import (
+	"maps"
+	"strings"
+
+	"ivf-calculator-backend/internal/i18n"
+)
+
+// Error codes identify the kind of problem independently of the message, so
+// clients can localize messages and highlight fields
+const (
+	CodeRequired      = "required"
+	CodeOutOfRange    = "out_of_range"
+	CodeInvalidValue  = "invalid_value"
+	CodeExclusive     = "exclusive"
+	CodeExceedsField  = "exceeds_field"
+	CodeUnitSystem    = "unit_system"
+	CodeNegative      = "negative"
+	CodeInvalidRange  = "invalid_range"
+	CodeTooManyPoints = "too_many_points"
+	CodeFractional    = "fractional"
+	CodeFieldCount    = "field_count"
+	CodeInvalidType   = "invalid_type"
+	CodeInvalidJSON   = "invalid_json"
+)
+
+// FieldError is one validation problem. Field is the JSON path of the request
+// field, or empty when the problem is with the body as a whole. Params holds
+// the values the message refers to, such as the allowed range.
+type FieldError struct {
+	Field   string         `json:"field"`
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+// Errors lists validation problems in the order they were found. A field can
+// have several problems.
+type Errors []FieldError
+
+// Error joins the problems as "field: message" pairs
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		if fe.Field == "" {
+			messages[i] = fe.Message
+		} else {
+			messages[i] = fe.Field + ": " + fe.Message
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Field returns the problems with the named field
+func (e Errors) Field(field string) Errors {
+	var found Errors
+	for _, fe := range e {
+		if fe.Field == field {
+			found = append(found, fe)
+		}
+	}
+	return found
+}
+
+func (e *Errors) add(field, code string, params map[string]any) {
+	*e = append(*e, newProblem("validation", field, code, params))
+}
+
+// NewError returns the validation problem with field, code and params, with
+// its English message from the catalog
+func NewError(field, code string, params map[string]any) FieldError {
+	return newProblem("validation", field, code, params)
+}
+
+// newProblem renders the English message of a problem from the catalog keys
+// under prefix. The code stands in for a message the catalog lacks.
+func newProblem(prefix, field, code string, params map[string]any) FieldError {
+	fe := FieldError{Field: field, Code: code, Params: params}
+	fe.Message = code
+	if message, ok := localizeMessage(catalogMessages(i18n.Default(), i18n.English), prefix, fe); ok {
+		fe.Message = message
+	}
+	return fe
+}
+
+// rangeParams returns the params of an out_of_range error
+func rangeParams[T int | float64](min, max T) map[string]any {
+	return map[string]any{"min": min, "max": max}
+}
+
+// Localize returns a copy of the errors with their messages in locale. A
+// message the catalog cannot render for locale keeps its English text.
+func (e Errors) Localize(catalog *i18n.Catalog, locale string) Errors {
+	if locale == i18n.English || len(e) == 0 {
+		return e
+	}
+
+	return localize(catalog, locale, "validation", e)
+}
+
+// localize returns a copy of problems with their messages rendered from the
+// catalog keys under prefix
+func localize(catalog *i18n.Catalog, locale, prefix string, problems []FieldError) []FieldError {
+	localized := make([]FieldError, len(problems))
+	for i, fe := range problems {
+		localized[i] = fe
+		if message, ok := localizeMessage(catalogMessages(catalog, locale), prefix, fe); ok {
+			localized[i].Message = message
+		}
+	}
+	return localized
+}
+
+// messageFunc renders the catalog message for key
+type messageFunc func(key string, params map[string]any) (string, bool)
+
+// catalogMessages renders messages in locale, falling back to English
+func catalogMessages(catalog *i18n.Catalog, locale string) messageFunc {
+	return func(key string, params map[string]any) (string, bool) {
+		return catalog.Message(locale, key, params)
+	}
+}
+
+// localizeMessage renders the message for a problem from the most specific of
+// "<prefix>.<code>.<field>" and "<prefix>.<code>"
+func localizeMessage(message messageFunc, prefix string, fe FieldError) (string, bool) {
+	field := fe.Field[strings.LastIndex(fe.Field, ".")+1:]
+	params := maps.Clone(fe.Params)
+	if params == nil {
+		params = make(map[string]any)
+	}
+
+	// A curve point error wraps the error of the field that failed at that point
+	if pointField, ok := params["field"].(string); ok && field == "range" {
+		pointMessage, ok := localizeMessage(message, prefix, FieldError{Field: pointField, Code: fe.Code, Params: fe.Params})
+		if !ok {
+			return "", false
+		}
+		params["message"] = pointMessage
+		return message(prefix+".range", params)
+	}
+
+	if value, ok := params["value"].(string); ok {
+		params["label"] = value
+		if label, ok := message("reason."+value, nil); ok {
+			params["label"] = label
+		}
+	}
+	for _, key := range []string{prefix + "." + fe.Code + "." + field, prefix + "." + fe.Code} {
+		if rendered, ok := message(key, params); ok {
+			return rendered, true
+		}
+	}
+	return "", false
+}
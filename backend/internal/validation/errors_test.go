@@ -0,0 +1,171 @@
+package validation
+
+import (
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/i18n"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestErrorsLocalize(t *testing.T) {
+	req := calculator.CalculateRequest{
+		Age:       19,
+		BMI:       22.8,
+		EggSource: "own",
+		Reasons:   []string{"unknown", "endometriosis"},
+	}
+	errs := ValidateCalculateRequest(req, formulaRetrievals)
+
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"en", []string{
+			"must be between 20 and 50",
+			"must be 'yes' or 'no' when planning to use 'own' eggs",
+			"'I don't know/no reason' must be selected by itself",
+		}},
+		{"es", []string{
+			"debe estar entre 20 y 50",
+			"debe ser 'yes' o 'no' si piensa usar sus propios óvulos",
+			"'No lo sé/sin motivo' debe seleccionarse por sí solo",
+		}},
+		{"zh", []string{
+			"必须在 20 到 50 之间",
+			"使用自己的卵子时必须为 'yes' 或 'no'",
+			"“我不知道/没有原因”必须单独选择",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			localized := errs.Localize(i18n.Default(), tt.locale)
+			if len(localized) != len(tt.want) {
+				t.Fatalf("Expected %d errors, got %+v", len(tt.want), []FieldError(localized))
+			}
+			for i, fe := range localized {
+				if fe.Message != tt.want[i] {
+					t.Errorf("Error %d = %q, want %q", i, fe.Message, tt.want[i])
+				}
+				if fe.Code != errs[i].Code || fe.Field != errs[i].Field {
+					t.Errorf("Error %d changed field or code: %+v", i, fe)
+				}
+			}
+		})
+	}
+
+	if errs[0].Message != "must be between 20 and 50" {
+		t.Errorf("Localize modified the original errors: %+v", errs[0])
+	}
+}
+
+func TestErrorsLocalize_CurvePoint(t *testing.T) {
+	req := calculator.CurveRequest{
+		Base: calculator.CalculateRequest{
+			Age: 40, BMI: 22.8, EggSource: "donor", Reasons: []string{"unknown"},
+		},
+		Dimension: calculator.CurveAge, From: 40, To: 55, Step: 5,
+	}
+
+	errs := ValidateCurveRequest(req, formulaRetrievals).Localize(i18n.Default(), "fr")
+	if len(errs) != 1 || errs[0].Message != "pour age 55 : age doit être compris entre 20 et 50" {
+		t.Errorf("Unexpected errors %+v", []FieldError(errs))
+	}
+}
+
+func TestErrorsLocalize_CurveStep(t *testing.T) {
+	req := calculator.CurveRequest{
+		Base: calculator.CalculateRequest{
+			Age: 40, BMI: 22.8, EggSource: "donor", Reasons: []string{"unknown"},
+		},
+		Dimension: calculator.CurveAge, From: 30, To: 35, Step: 0.5,
+	}
+
+	errs := ValidateCurveRequest(req, formulaRetrievals).Localize(i18n.Default(), "es")
+	if len(errs) != 1 || errs[0].Field != "step" || errs[0].Message != "debe ser un número entero para age" {
+		t.Errorf("Unexpected errors %+v", []FieldError(errs))
+	}
+}
+
+func TestErrorsLocalize_FallsBackToEnglish(t *testing.T) {
+	errs := Errors{{Field: "age", Code: CodeInvalidType, Message: "must be a whole number", Params: map[string]any{"got": "string"}}}
+
+	if got := errs.Localize(i18n.Default(), "es")[0].Message; got != "must be a whole number" {
+		t.Errorf("Expected the English message when the template cannot be rendered, got %q", got)
+	}
+}
+
+// TestMessagesInEveryLocale checks every problem the validators emit has its
+// message in each locale, without falling back to English
+func TestMessagesInEveryLocale(t *testing.T) {
+	base := calculator.CalculateRequest{Age: 30, BMI: 22.8, EggSource: "donor", Reasons: []string{"unknown"}}
+	var problems []FieldError
+	validation := func(errs Errors) {
+		problems = append(problems, errs...)
+	}
+
+	validation(ValidateCalculateRequest(calculator.CalculateRequest{
+		Age: 19, EggSource: "own", Reasons: []string{"bogus", "unknown"}, PriorPregnancies: 3, PriorBirths: 1, Rounding: "up",
+	}, formulaRetrievals))
+	validation(ValidateCalculateRequest(calculator.CalculateRequest{
+		Age: 30, WeightKg: 60, BMI: 22.8, EggSource: "bogus", PriorIvfCycles: "maybe", PriorBirths: 1,
+	}, formulaRetrievals))
+	validation(ValidateCurveRequest(calculator.CurveRequest{Base: base, Dimension: "height", Step: 0, From: 2, To: 1}, formulaRetrievals))
+	validation(ValidateCurveRequest(calculator.CurveRequest{Base: base, Dimension: calculator.CurveBMI, From: 20, To: 1e6, Step: 0.001}, formulaRetrievals))
+	validation(ValidateCurveRequest(calculator.CurveRequest{Base: base, Dimension: calculator.CurveAge, From: 30.5, To: 35, Step: 1}, formulaRetrievals))
+	validation(ValidateCurveRequest(calculator.CurveRequest{Base: base, Dimension: calculator.CurveWeight, From: 100, To: 120, Step: 10}, formulaRetrievals))
+	validation(ValidateCurveRequest(calculator.CurveRequest{Base: base, Dimension: calculator.CurveAge, From: 45, To: 55, Step: 5}, formulaRetrievals))
+	validation(ValidateSimulationRequest(calculator.SimulationRequest{
+		Base: base, Draws: -1, Percentiles: []float64{101},
+		StandardErrors: map[string]float64{"age": -1}, InputNoise: calculator.InputNoise{Weight: -1},
+	}, formulaRetrievals))
+	for _, body := range []string{`{"bmi": 22.8, "heightIn": -1}`, `{"age": "thirty"}`, `{"age": `} {
+		target := &calculator.CalculateRequest{}
+		validation(BindingErrors(binding.JSON.BindBody([]byte(body), target), target))
+	}
+	problems = append(problems,
+		NewError("", CodeInvalidJSON, nil),
+		NewError("age", CodeInvalidValue, map[string]any{"rule": "oneof"}),
+		NewError("range", CodeInvalidValue, nil),
+		NewError("", CodeFieldCount, map[string]any{"fields": 8, "expected": 9}),
+	)
+
+	var warnings []FieldError
+	warnings = append(warnings, WarnCalculateRequest(calculator.CalculateRequest{Age: 47, BMI: 52, EggSource: "own"})...)
+	warnings = append(warnings, WarnCalculateRequest(calculator.CalculateRequest{Age: 30, BMI: 15, EggSource: "own"})...)
+
+	// Every code is emitted, so a new one cannot be left out of the catalog
+	codes := make(map[string]bool)
+	for _, fe := range problems {
+		codes[fe.Code] = true
+	}
+	for _, code := range []string{
+		CodeRequired, CodeOutOfRange, CodeInvalidValue, CodeExclusive, CodeExceedsField, CodeUnitSystem, CodeNegative,
+		CodeInvalidRange, CodeTooManyPoints, CodeFractional, CodeFieldCount, CodeInvalidType, CodeInvalidJSON,
+	} {
+		if !codes[code] {
+			t.Errorf("No problem with code %s was emitted", code)
+		}
+	}
+	if len(warnings) != 3 {
+		t.Errorf("Expected every warning, got %+v", warnings)
+	}
+
+	catalog := i18n.Default()
+	for _, locale := range catalog.Locales() {
+		strict := func(key string, params map[string]any) (string, bool) {
+			return catalog.LocaleMessage(locale, key, params)
+		}
+		for prefix, list := range map[string][]FieldError{"validation": problems, "warning": warnings} {
+			for _, fe := range list {
+				message, ok := localizeMessage(strict, prefix, fe)
+				if !ok {
+					t.Errorf("%s: no %s message for %s %s with params %v", locale, prefix, fe.Field, fe.Code, fe.Params)
+				} else if locale == i18n.English && message != fe.Message {
+					t.Errorf("%s %s: message %q, rendered %q", fe.Field, fe.Code, fe.Message, message)
+				}
+			}
+		}
+	}
+}
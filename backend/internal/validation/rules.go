@@ -0,0 +1,287 @@
+package validation
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/i18n"
+)
+
+// Field types of a Rule
+const (
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeString  = "string"
+	TypeStrings = "string[]"
+)
+
+// Kinds of Constraint
+const (
+	ConstraintOneUnitSystem = "one_unit_system"
+	ConstraintRequiredIf    = "required_if"
+	ConstraintNotAbove      = "not_above"
+	ConstraintExclusive     = "exclusive"
+)
+
+// Rule declares what is accepted for one request field. Min and Max are
+// inclusive. A rule with a UnitSystem only applies when that unit system is used.
+type Rule struct {
+	Field      string   `json:"field"`
+	Type       string   `json:"type"`
+	Required   bool     `json:"required,omitempty"`
+	Min        *float64 `json:"min,omitempty"`
+	Max        *float64 `json:"max,omitempty"`
+	Default    *float64 `json:"default,omitempty"` // used when the field is omitted or 0
+	Enum       []string `json:"enum,omitempty"`    // for string[] fields, the allowed items
+	MinItems   int      `json:"minItems,omitempty"`
+	UnitSystem string   `json:"unitSystem,omitempty"`
+	// Labels are display names of the Enum values, filled in for a locale
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// labelKey prefixes the Enum values to form their catalog message keys
+	labelKey string
+}
+
+// UnitGroup is the set of fields of one unit system
+type UnitGroup struct {
+	UnitSystem string   `json:"unitSystem"`
+	Fields     []string `json:"fields"`
+}
+
+// Constraint declares a rule spanning several fields:
+//   - one_unit_system: exactly one of Groups is used; Default is checked when none is
+//   - required_if: Field is required when Other has one of Values
+//   - not_above: Field must not be greater than Other
+//   - exclusive: each of Values must be the only item of Field
+type Constraint struct {
+	Kind    string      `json:"kind"`
+	Field   string      `json:"field,omitempty"`
+	Other   string      `json:"other,omitempty"`
+	Values  []string    `json:"values,omitempty"`
+	Groups  []UnitGroup `json:"groups,omitempty"`
+	Default string      `json:"default,omitempty"`
+}
+
+// Schema declares the rules of a request. It is served to clients so forms
+// can enforce the same rules as the server.
+type Schema struct {
+	Fields      []Rule       `json:"fields"`
+	Constraints []Constraint `json:"constraints"`
+}
+
+// Rule returns the rule for the named field
+func (s Schema) Rule(field string) (Rule, bool) {
+	for _, rule := range s.Fields {
+		if rule.Field == field {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Localize returns a copy of the schema with the Enum labels of its rules in locale
+func (s Schema) Localize(catalog *i18n.Catalog, locale string) Schema {
+	fields := slices.Clone(s.Fields)
+	for i, rule := range fields {
+		if rule.labelKey == "" {
+			continue
+		}
+		fields[i].Labels = make(map[string]string, len(rule.Enum))
+		for _, value := range rule.Enum {
+			fields[i].Labels[value] = catalog.Text(locale, rule.labelKey+value, value)
+		}
+	}
+	return Schema{Fields: fields, Constraints: s.Constraints}
+}
+
+// WithMaxRetrievals returns a copy of the schema accepting up to n intended
+// retrievals, the most the loaded formulas can estimate
+func (s Schema) WithMaxRetrievals(n int) Schema {
+	fields := slices.Clone(s.Fields)
+	for i := range fields {
+		if fields[i].Field == "retrievals" {
+			fields[i].Max = limit(float64(n))
+		}
+	}
+	return Schema{Fields: fields, Constraints: s.Constraints}
+}
+
+func limit(v float64) *float64 { return &v }
+
+// CalculateRequestSchema declares everything ValidateCalculateRequest enforces
+var CalculateRequestSchema = Schema{
+	Fields: []Rule{
+		{Field: "age", Type: TypeInteger, Required: true, Min: limit(MinAge), Max: limit(MaxAge)},
+		{Field: "weightLbs", Type: TypeInteger, Min: limit(MinWeightLbs), Max: limit(MaxWeightLbs), UnitSystem: calculator.UnitsImperial},
+		{Field: "heightFt", Type: TypeInteger, Min: limit(MinHeightFt), Max: limit(MaxHeightFt), UnitSystem: calculator.UnitsImperial},
+		{Field: "heightIn", Type: TypeInteger, Min: limit(MinHeightIn), Max: limit(MaxHeightIn), UnitSystem: calculator.UnitsImperial},
+		{Field: "weightKg", Type: TypeNumber, Min: limit(MinWeightKg), Max: limit(MaxWeightKg), UnitSystem: calculator.UnitsMetric},
+		{Field: "heightCm", Type: TypeNumber, Min: limit(MinHeightCm), Max: limit(MaxHeightCm), UnitSystem: calculator.UnitsMetric},
+		{Field: "bmi", Type: TypeNumber, Min: limit(MinBMI), Max: limit(MaxBMI), UnitSystem: calculator.UnitsBMI},
+		{Field: "eggSource", Type: TypeString, Required: true, Enum: EggSources},
+		{Field: "priorIvfCycles", Type: TypeString, Enum: PriorIvfCyclesOptions},
+		// The most retrievals depends on the loaded formulas, see WithMaxRetrievals
+		{Field: "retrievals", Type: TypeInteger, Min: limit(MinRetrievals), Max: limit(MinRetrievals), Default: limit(1)},
+		{Field: "priorPregnancies", Type: TypeInteger, Min: limit(0), Max: limit(MaxPriorPregnancies)},
+		{Field: "priorBirths", Type: TypeInteger, Min: limit(0), Max: limit(MaxPriorPregnancies)},
+		{Field: "reasons", Type: TypeStrings, Required: true, Enum: Reasons, MinItems: 1, labelKey: "reason."},
+		{Field: "confidenceLevel", Type: TypeNumber, Min: limit(MinConfidenceLevel), Max: limit(MaxConfidenceLevel), Default: limit(calculator.DefaultConfidenceLevel)},
+		{Field: "rounding", Type: TypeString, Enum: calculator.RoundingPolicies},
+	},
+	Constraints: []Constraint{
+		{
+			Kind: ConstraintOneUnitSystem,
+			Groups: []UnitGroup{
+				{UnitSystem: calculator.UnitsImperial, Fields: []string{"weightLbs", "heightFt", "heightIn"}},
+				{UnitSystem: calculator.UnitsMetric, Fields: []string{"weightKg", "heightCm"}},
+				{UnitSystem: calculator.UnitsBMI, Fields: []string{"bmi"}},
+			},
+			Default: calculator.UnitsImperial,
+		},
+		{Kind: ConstraintRequiredIf, Field: "priorIvfCycles", Other: "eggSource", Values: []string{"own"}},
+		{Kind: ConstraintNotAbove, Field: "priorBirths", Other: "priorPregnancies"},
+		{Kind: ConstraintExclusive, Field: "reasons", Values: []string{"unexplained", "unknown"}},
+	},
+}
+
+// validate checks a request against every rule and then every constraint
+func (s Schema) validate(req calculator.CalculateRequest) Errors {
+	var errors Errors
+	fields := jsonFields(reflect.ValueOf(req))
+	systems := s.unitSystems(fields)
+
+	for _, rule := range s.Fields {
+		if rule.UnitSystem != "" && !slices.Contains(systems, rule.UnitSystem) {
+			continue
+		}
+		value := fields[rule.Field]
+
+		switch rule.Type {
+		case TypeInteger, TypeNumber:
+			n := number(value)
+			if rule.Default != nil && n == 0 {
+				continue
+			}
+			if (rule.Min != nil && n < *rule.Min) || (rule.Max != nil && n > *rule.Max) {
+				errors.add(rule.Field, CodeOutOfRange, rule.rangeParams())
+			}
+		case TypeString:
+			v := value.String()
+			if v == "" && !rule.Required {
+				continue
+			}
+			if len(rule.Enum) > 0 && !slices.Contains(rule.Enum, v) {
+				errors.add(rule.Field, CodeInvalidValue, map[string]any{"allowed": rule.Enum})
+			}
+		case TypeStrings:
+			items := value.Interface().([]string)
+			if len(items) < rule.MinItems {
+				errors.add(rule.Field, CodeRequired, nil)
+			}
+			for _, item := range items {
+				if len(rule.Enum) > 0 && !slices.Contains(rule.Enum, item) {
+					errors.add(rule.Field, CodeInvalidValue, map[string]any{"value": item, "allowed": rule.Enum})
+				}
+			}
+		}
+	}
+
+	for _, constraint := range s.Constraints {
+		constraint.check(fields, &errors)
+	}
+	return errors
+}
+
+// unitSystems returns the unit systems whose fields are set, or the default
+// unit system when none is, so its fields are reported as missing
+func (s Schema) unitSystems(fields map[string]reflect.Value) []string {
+	for _, constraint := range s.Constraints {
+		if constraint.Kind != ConstraintOneUnitSystem {
+			continue
+		}
+		systems := constraint.usedGroups(fields)
+		if len(systems) == 0 && constraint.Default != "" {
+			return []string{constraint.Default}
+		}
+		return systems
+	}
+	return nil
+}
+
+// usedGroups returns the unit systems with at least one field set
+func (c Constraint) usedGroups(fields map[string]reflect.Value) []string {
+	var systems []string
+	for _, group := range c.Groups {
+		for _, field := range group.Fields {
+			if !fields[field].IsZero() {
+				systems = append(systems, group.UnitSystem)
+				break
+			}
+		}
+	}
+	return systems
+}
+
+func (c Constraint) check(fields map[string]reflect.Value, errors *Errors) {
+	switch c.Kind {
+	case ConstraintOneUnitSystem:
+		if used := c.usedGroups(fields); len(used) != 1 {
+			errors.add("units", CodeUnitSystem, map[string]any{"given": append([]string{}, used...)})
+		}
+	case ConstraintRequiredIf:
+		other := fields[c.Other].String()
+		if slices.Contains(c.Values, other) && fields[c.Field].IsZero() {
+			errors.add(c.Field, CodeRequired, map[string]any{"allowed": enumOf(c.Field), c.Other: other})
+		}
+	case ConstraintNotAbove:
+		if number(fields[c.Field]) > number(fields[c.Other]) {
+			errors.add(c.Field, CodeExceedsField, map[string]any{"field": c.Other})
+		}
+	case ConstraintExclusive:
+		items := fields[c.Field].Interface().([]string)
+		for _, value := range c.Values {
+			if slices.Contains(items, value) && len(items) != 1 {
+				errors.add(c.Field, CodeExclusive, map[string]any{"value": value})
+			}
+		}
+	}
+}
+
+// enumOf returns the accepted values of a field of CalculateRequestSchema
+func enumOf(field string) []string {
+	rule, _ := CalculateRequestSchema.Rule(field)
+	return rule.Enum
+}
+
+// rangeParams returns the limits as integers for integer fields
+func (r Rule) rangeParams() map[string]any {
+	if r.Type == TypeInteger {
+		return rangeParams(int(*r.Min), int(*r.Max))
+	}
+	return rangeParams(*r.Min, *r.Max)
+}
+
+// number returns an integer or float field as a float64
+func number(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Float64:
+		return v.Float()
+	}
+	return 0
+}
+
+// jsonFields indexes the fields of a struct value by their JSON names
+func jsonFields(v reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = v.Field(i)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,53 @@
+package validation
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"ivf-calculator-backend/internal/calculator"
+)
+
+func TestCalculateRequestSchema_FieldsExist(t *testing.T) {
+	fields := jsonFields(reflect.ValueOf(calculator.CalculateRequest{}))
+
+	for _, rule := range CalculateRequestSchema.Fields {
+		if _, ok := fields[rule.Field]; !ok {
+			t.Errorf("rule for unknown field %q", rule.Field)
+		}
+	}
+	for _, constraint := range CalculateRequestSchema.Constraints {
+		names := []string{constraint.Field, constraint.Other}
+		for _, group := range constraint.Groups {
+			names = append(names, group.Fields...)
+		}
+		for _, name := range names {
+			if _, ok := fields[name]; name != "" && !ok {
+				t.Errorf("%s constraint on unknown field %q", constraint.Kind, name)
+			}
+		}
+	}
+}
+
+func TestCalculateRequestSchema_UnitGroupsMatchRequest(t *testing.T) {
+	// Each group's fields must be exactly the ones that put a request in that unit system
+	for _, constraint := range CalculateRequestSchema.Constraints {
+		if constraint.Kind != ConstraintOneUnitSystem {
+			continue
+		}
+		for _, group := range constraint.Groups {
+			for _, field := range group.Fields {
+				req := calculator.CalculateRequest{}
+				value := jsonFields(reflect.ValueOf(&req).Elem())[field]
+				if value.Kind() == reflect.Float64 {
+					value.SetFloat(1)
+				} else {
+					value.SetInt(1)
+				}
+				if systems := req.UnitSystems(); !slices.Equal(systems, []string{group.UnitSystem}) {
+					t.Errorf("%s sets unit systems %v, want [%s]", field, systems, group.UnitSystem)
+				}
+			}
+		}
+	}
+}
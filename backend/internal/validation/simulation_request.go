@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"fmt"
+	"ivf-calculator-backend/internal/calculator"
+	"slices"
+)
+
+// ValidateSimulationRequest validates the simulation request and returns
+// errors if any. Errors in the base patient are prefixed with "base.".
+func ValidateSimulationRequest(req calculator.SimulationRequest, maxRetrievals int) Errors {
+	var errors Errors
+
+	for _, fe := range ValidateCalculateRequest(req.Base, maxRetrievals) {
+		fe.Field = "base." + fe.Field
+		errors = append(errors, fe)
+	}
+
+	if req.Draws < 1 || req.Draws > calculator.MaxSimulationDraws {
+		errors.add("draws", CodeOutOfRange, rangeParams(1, calculator.MaxSimulationDraws))
+	}
+
+	if req.Buckets < 0 || req.Buckets > calculator.MaxSimulationBuckets {
+		errors.add("buckets", CodeOutOfRange, rangeParams(1, calculator.MaxSimulationBuckets))
+	}
+
+	for i, p := range req.Percentiles {
+		if !(p >= 0 && p <= 100) {
+			errors.add(fmt.Sprintf("percentiles[%d]", i), CodeOutOfRange, rangeParams(0, 100))
+		}
+	}
+
+	// Sorted so the errors come in the same order for every request
+	names := make([]string, 0, len(req.StandardErrors))
+	for name := range req.StandardErrors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		switch se := req.StandardErrors[name]; {
+		case !(se >= 0):
+			errors.add("standardErrors."+name, CodeNegative, nil)
+		case se > calculator.MaxStandardError:
+			errors.add("standardErrors."+name, CodeOutOfRange, rangeParams(0, calculator.MaxStandardError))
+		}
+	}
+
+	noise := []struct {
+		field string
+		sd    float64
+	}{
+		{"inputNoise.weight", req.InputNoise.Weight},
+		{"inputNoise.height", req.InputNoise.Height},
+		{"inputNoise.bmi", req.InputNoise.BMI},
+	}
+	for _, n := range noise {
+		if !(n.sd >= 0) {
+			errors.add(n.field, CodeNegative, nil)
+		}
+	}
+
+	// Noise applies to the measurements of the base patient, so it must be
+	// given in the same unit system
+	systems := req.Base.UnitSystems()
+	if len(systems) == 1 {
+		bmi := systems[0] == calculator.UnitsBMI
+		if bmi && (req.InputNoise.Weight != 0 || req.InputNoise.Height != 0) || !bmi && req.InputNoise.BMI != 0 {
+			errors.add("inputNoise", CodeUnitSystem, map[string]any{"given": systems})
+		}
+	}
+
+	return errors
+}
@@ -0,0 +1,90 @@
+package validation
+
+import (
+	"ivf-calculator-backend/internal/calculator"
+	"reflect"
+	"testing"
+)
+
+func TestValidateSimulationRequest(t *testing.T) {
+	base := calculator.CalculateRequest{
+		Age:              30,
+		WeightLbs:        150,
+		HeightFt:         5,
+		HeightIn:         6,
+		EggSource:        "own",
+		PriorIvfCycles:   "no",
+		PriorPregnancies: 1,
+		PriorBirths:      1,
+		Reasons:          []string{"male_factor_infertility"},
+	}
+	bmi := calculator.CalculateRequest{Age: 30, BMI: 22, EggSource: "donor", Reasons: []string{"unknown"}}
+
+	tests := []struct {
+		name     string
+		req      calculator.SimulationRequest
+		wantErrs Errors
+	}{
+		{
+			name: "valid",
+			req: calculator.SimulationRequest{
+				Base: base, Draws: 1000, StandardErrors: map[string]float64{"intercept": 0.2},
+				InputNoise: calculator.InputNoise{Weight: 3, Height: 0.5}, Percentiles: []float64{0, 50, 100}, Buckets: 10,
+			},
+			wantErrs: nil,
+		},
+		{
+			name: "out of range settings",
+			req:  calculator.SimulationRequest{Base: base, Draws: 2_000_000, Percentiles: []float64{50, 101}, Buckets: 500},
+			wantErrs: Errors{
+				{"draws", CodeOutOfRange, "must be between 1 and 1000000", map[string]any{"min": 1, "max": 1000000}},
+				{"buckets", CodeOutOfRange, "must be between 1 and 100", map[string]any{"min": 1, "max": 100}},
+				{"percentiles[1]", CodeOutOfRange, "must be between 0 and 100", map[string]any{"min": 0, "max": 100}},
+			},
+		},
+		{
+			name: "negative uncertainty",
+			req: calculator.SimulationRequest{
+				Base: base, Draws: 10, StandardErrors: map[string]float64{"intercept": -0.2, "age_linear": -0.01},
+				InputNoise: calculator.InputNoise{Height: -1},
+			},
+			wantErrs: Errors{
+				{"standardErrors.age_linear", CodeNegative, "must not be negative", nil},
+				{"standardErrors.intercept", CodeNegative, "must not be negative", nil},
+				{"inputNoise.height", CodeNegative, "must not be negative", nil},
+			},
+		},
+		{
+			name: "standard error too large",
+			req:  calculator.SimulationRequest{Base: base, Draws: 10, StandardErrors: map[string]float64{"intercept": 1000}},
+			wantErrs: Errors{
+				{"standardErrors.intercept", CodeOutOfRange, "must be between 0 and 10", map[string]any{"min": 0, "max": 10}},
+			},
+		},
+		{
+			name: "noise of another unit system",
+			req:  calculator.SimulationRequest{Base: bmi, Draws: 10, InputNoise: calculator.InputNoise{Weight: 2}},
+			wantErrs: Errors{
+				{"inputNoise", CodeUnitSystem, "must match the base patient: weight and height for weight and height, bmi for bmi",
+					map[string]any{"given": []string{"bmi"}}},
+			},
+		},
+		{
+			name: "invalid base request",
+			req:  calculator.SimulationRequest{Base: calculator.CalculateRequest{Age: 30, BMI: 22, EggSource: "donor"}, Draws: 10},
+			wantErrs: Errors{
+				{"base.reasons", CodeRequired, "at least one reason must be selected", nil},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErrs := ValidateSimulationRequest(tt.req, formulaRetrievals)
+
+			if !reflect.DeepEqual(gotErrs, tt.wantErrs) {
+				t.Errorf("ValidateSimulationRequest() = %+v, want %+v", []FieldError(gotErrs), []FieldError(tt.wantErrs))
+			}
+		})
+	}
+}
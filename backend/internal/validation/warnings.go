@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"math"
+
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/i18n"
+)
+
+// Warning codes identify inputs that are valid but outside where the CDC
+// model is well calibrated
+const (
+	WarningBMIBelowCalibration = "bmi_below_calibration"
+	WarningBMIAboveCalibration = "bmi_above_calibration"
+	WarningAgeOwnEggs          = "age_own_eggs"
+)
+
+// Limits of the inputs the CDC model is well calibrated for. Inputs outside
+// them are accepted with a warning.
+const (
+	CalibratedMinBMI        = 16
+	CalibratedMaxBMI        = 50
+	CalibratedMaxAgeOwnEggs = 45
+)
+
+// Warnings lists caveats about a valid request, in the same shape as Errors.
+// They do not stop the calculation.
+type Warnings []FieldError
+
+// Localize returns a copy of the warnings with their messages in locale
+func (w Warnings) Localize(catalog *i18n.Catalog, locale string) Warnings {
+	if locale == i18n.English || len(w) == 0 {
+		return w
+	}
+	return localize(catalog, locale, "warning", w)
+}
+
+func (w *Warnings) add(field, code string, params map[string]any) {
+	*w = append(*w, newProblem("warning", field, code, params))
+}
+
+// WarnCalculateRequest returns the caveats of a request that passed
+// ValidateCalculateRequest. BMI is checked after converting from whichever
+// unit system the request uses.
+func WarnCalculateRequest(req calculator.CalculateRequest) Warnings {
+	var warnings Warnings
+
+	if bmi, err := req.BodyMassIndex(); err == nil {
+		rounded := math.Round(bmi*10) / 10
+		switch {
+		case bmi < CalibratedMinBMI:
+			warnings.add("bmi", WarningBMIBelowCalibration, map[string]any{"bmi": rounded, "min": CalibratedMinBMI})
+		case bmi > CalibratedMaxBMI:
+			warnings.add("bmi", WarningBMIAboveCalibration, map[string]any{"bmi": rounded, "max": CalibratedMaxBMI})
+		}
+	}
+
+	if req.EggSource == "own" && req.Age > CalibratedMaxAgeOwnEggs {
+		warnings.add("age", WarningAgeOwnEggs, map[string]any{"age": req.Age, "max": CalibratedMaxAgeOwnEggs})
+	}
+
+	return warnings
+}
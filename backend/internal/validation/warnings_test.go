@@ -0,0 +1,85 @@
+package validation
+
+import (
+	"ivf-calculator-backend/internal/calculator"
+	"ivf-calculator-backend/internal/i18n"
+	"reflect"
+	"testing"
+)
+
+func TestWarnCalculateRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		req          calculator.CalculateRequest
+		wantWarnings Warnings
+	}{
+		{
+			name:         "well calibrated inputs",
+			req:          calculator.CalculateRequest{Age: 32, WeightLbs: 150, HeightFt: 5, HeightIn: 6, EggSource: "own"},
+			wantWarnings: nil,
+		},
+		{
+			name: "low BMI given directly",
+			req:  calculator.CalculateRequest{Age: 32, BMI: 15.2, EggSource: "donor"},
+			wantWarnings: Warnings{
+				{"bmi", WarningBMIBelowCalibration, "a BMI of 15.2 is below 16, where the model is less reliable",
+					map[string]any{"bmi": 15.2, "min": CalibratedMinBMI}},
+			},
+		},
+		{
+			name: "high BMI computed from allowed weight and height",
+			req:  calculator.CalculateRequest{Age: 32, WeightLbs: 300, HeightFt: 5, HeightIn: 0, EggSource: "donor"},
+			wantWarnings: Warnings{
+				{"bmi", WarningBMIAboveCalibration, "a BMI of 58.6 is above 50, where the model is less reliable",
+					map[string]any{"bmi": 58.6, "max": CalibratedMaxBMI}},
+			},
+		},
+		{
+			name: "BMI at the calibrated limit",
+			req:  calculator.CalculateRequest{Age: 32, BMI: 50, EggSource: "donor"},
+		},
+		{
+			name: "over 45 with own eggs",
+			req:  calculator.CalculateRequest{Age: 46, WeightKg: 60, HeightCm: 165, EggSource: "own"},
+			wantWarnings: Warnings{
+				{"age", WarningAgeOwnEggs, "few patients over 45 used their own eggs, so the model is less reliable",
+					map[string]any{"age": 46, "max": CalibratedMaxAgeOwnEggs}},
+			},
+		},
+		{
+			name: "over 45 with donor eggs",
+			req:  calculator.CalculateRequest{Age: 46, WeightKg: 60, HeightCm: 165, EggSource: "donor"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotWarnings := WarnCalculateRequest(tt.req)
+
+			if !reflect.DeepEqual(gotWarnings, tt.wantWarnings) {
+				t.Errorf("WarnCalculateRequest() = %+v, want %+v", []FieldError(gotWarnings), []FieldError(tt.wantWarnings))
+			}
+		})
+	}
+}
+
+func TestWarningsLocalize(t *testing.T) {
+	warnings := WarnCalculateRequest(calculator.CalculateRequest{Age: 47, BMI: 52, EggSource: "own"})
+
+	localized := warnings.Localize(i18n.Default(), "es")
+	want := []string{
+		"un IMC de 52 es superior a 50, donde el modelo es menos fiable",
+		"pocas pacientes mayores de 45 usaron sus propios óvulos, por lo que el modelo es menos fiable",
+	}
+	if len(localized) != len(want) {
+		t.Fatalf("Expected %d warnings, got %+v", len(want), []FieldError(localized))
+	}
+	for i, warning := range localized {
+		if warning.Message != want[i] {
+			t.Errorf("Warning %d = %q, want %q", i, warning.Message, want[i])
+		}
+	}
+	if warnings[0].Message != "a BMI of 52 is above 50, where the model is less reliable" {
+		t.Errorf("Localize modified the original warnings: %+v", warnings[0])
+	}
+}
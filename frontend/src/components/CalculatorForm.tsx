@@ -1,6 +1,16 @@
-import { useState } from 'react'
+import { useEffect, useState } from 'react'
+import { fetchSchema } from '../lib/api'
 import { eggSources, priorIvfCyclesOptions } from '../types/calculate'
-import type { CalculateFormData, CalculateReason, EggSource, FormErrors, PriorIvfCyclesOption } from '../types/calculate'
+import type { CalculateFormData, CalculateReason, EggSource, FormErrors, PriorIvfCyclesOption, ValidationSchema } from '../types/calculate'
+
+// Used until the rules are loaded from the server, or if they cannot be
+const defaultRanges: Record<string, { min: number; max: number }> = {
+  age: { min: 20, max: 50 },
+  weightLbs: { min: 80, max: 300 },
+  heightFt: { min: 4, max: 6 },
+  heightIn: { min: 0, max: 11 },
+}
+const defaultExclusiveReasons = ['unexplained', 'unknown']
 
 interface CalculatorFormProps {
   onSubmit: (data: CalculateFormData) => void
@@ -22,30 +32,50 @@ export default function CalculatorForm({ onSubmit, isSubmitting = false }: Calcu
   })
 
   const [errors, setErrors] = useState<FormErrors>({})
+  const [schema, setSchema] = useState<ValidationSchema>()
+
+  useEffect(() => {
+    fetchSchema().then(setSchema).catch(() => setSchema(undefined))
+  }, [])
+
+  const range = (field: string) => {
+    const rule = schema?.fields.find((f) => f.field === field)
+    return {
+      min: rule?.min ?? defaultRanges[field].min,
+      max: rule?.max ?? defaultRanges[field].max,
+    }
+  }
+  const exclusiveReasons =
+    schema?.constraints.find((c) => c.kind === 'exclusive' && c.field === 'reasons')?.values ?? defaultExclusiveReasons
+  const reasonLabels = schema?.fields.find((f) => f.field === 'reasons')?.labels ?? {}
 
   const validateField = (name: keyof CalculateFormData, value: string | CalculateReason[] | EggSource): string | undefined => {
     if (name === 'age') {
       const age = Number(value)
-      if (isNaN(age) || age < 20 || age > 50) {
-        return 'Age must be between 20 and 50'
+      const { min, max } = range('age')
+      if (isNaN(age) || age < min || age > max) {
+        return `Age must be between ${min} and ${max}`
       }
     }
     if (name === 'weightLbs') {
       const weight = Number(value)
-      if (isNaN(weight) || weight < 80 || weight > 300) {
-        return 'Weight must be between 80 and 300 lbs'
+      const { min, max } = range('weightLbs')
+      if (isNaN(weight) || weight < min || weight > max) {
+        return `Weight must be between ${min} and ${max} lbs`
       }
     }
     if (name === 'heightFt') {
       const heightFt = Number(value)
-      if (isNaN(heightFt) || heightFt < 4 || heightFt > 6) {
-        return 'Height feet must be between 4 and 7 feet'
+      const { min, max } = range('heightFt')
+      if (isNaN(heightFt) || heightFt < min || heightFt > max) {
+        return `Height feet must be between ${min} and ${max} feet`
       }
     }
     if (name === 'heightIn') {
       const heightIn = Number(value)
-      if (isNaN(heightIn) || heightIn < 0 || heightIn > 11) {
-        return 'Height inches must be between 0 and 12 inches'
+      const { min, max } = range('heightIn')
+      if (isNaN(heightIn) || heightIn < min || heightIn > max) {
+        return `Height inches must be between ${min} and ${max} inches`
       }
     }
     if (name === 'eggSource') {
@@ -106,7 +136,7 @@ export default function CalculatorForm({ onSubmit, isSubmitting = false }: Calcu
       newReasons = formData.reasons.includes(reason)
       ? formData.reasons.filter((r) => r !== reason )
       : [...formData.reasons, reason]
-      newReasons = newReasons.filter((r) => !['unexplained', 'unknown'].includes(r))
+      newReasons = newReasons.filter((r) => !exclusiveReasons.includes(r))
     }
     handleChange('reasons', newReasons)
   }
@@ -139,9 +169,13 @@ export default function CalculatorForm({ onSubmit, isSubmitting = false }: Calcu
     { value: 'diminished_ovarian_reserve', label: 'Diminished ovarian reserve' },
     { value: 'uterine_factor', label: 'Uterine factor' },
     { value: 'other', label: 'Other reason' },
-    { value: 'unexplained', label: 'Unexplained (Idiopathic) infertility', or: true },
-    { value: 'unknown', label: "I don't know/no reason", or: true },
-  ]
+    { value: 'unexplained', label: 'Unexplained (Idiopathic) infertility' },
+    { value: 'unknown', label: "I don't know/no reason" },
+  ].map((reason) => ({
+    value: reason.value as CalculateReason,
+    label: reasonLabels[reason.value] ?? reason.label,
+    or: exclusiveReasons.includes(reason.value),
+  }))
 
   return (
     <form onSubmit={handleSubmit} className="space-y-4">
@@ -152,9 +186,9 @@ export default function CalculatorForm({ onSubmit, isSubmitting = false }: Calcu
         <input
           type="number"
           id="age"
-          min="20"
-          max="50"
-          placeholder="Enter age between 20 and 50 years"
+          min={range('age').min}
+          max={range('age').max}
+          placeholder={`Enter age between ${range('age').min} and ${range('age').max} years`}
           value={formData.age}
           onChange={(e) => handleChange('age', e.target.value)}
           className={`w-full px-3 py-2 border rounded-md ${errors.age ? 'border-red-500' : 'border-gray-300'}`}
@@ -169,9 +203,9 @@ export default function CalculatorForm({ onSubmit, isSubmitting = false }: Calcu
         <input
           type="number"
           id="weightLbs"
-          min="80"
-          max="300"
-          placeholder="Enter weight between 80-300 lbs"
+          min={range('weightLbs').min}
+          max={range('weightLbs').max}
+          placeholder={`Enter weight between ${range('weightLbs').min}-${range('weightLbs').max} lbs`}
           value={formData.weightLbs}
           onChange={(e) => handleChange('weightLbs', e.target.value)}
           className={`w-full px-3 py-2 border rounded-md ${errors.weightLbs ? 'border-red-500' : 'border-gray-300'}`}
@@ -186,8 +220,8 @@ export default function CalculatorForm({ onSubmit, isSubmitting = false }: Calcu
             <input
               type="number"
               id="heightFeet"
-              min="4"
-              max="6"
+              min={range('heightFt').min}
+              max={range('heightFt').max}
               placeholder="feet"
               value={formData.heightFt}
               onChange={(e) => handleChange('heightFt', e.target.value)}
@@ -199,8 +233,8 @@ export default function CalculatorForm({ onSubmit, isSubmitting = false }: Calcu
             <input
               type="number"
               id="heightInches"
-              min="0"
-              max="11"
+              min={range('heightIn').min}
+              max={range('heightIn').max}
               placeholder="inches"
               value={formData.heightIn}
               onChange={(e) => handleChange('heightIn', e.target.value)}
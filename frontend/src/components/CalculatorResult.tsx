@@ -50,8 +50,24 @@ export default function CalculatorResult({
             {result.cumulativeChancePercent}%
           </span>
         </div>
+        {result.confidenceInterval?.available && (
+          <p className="mt-1 text-sm text-gray-600">
+            {Math.round(result.confidenceInterval.level * 100)}% confidence interval:{' '}
+            {result.confidenceInterval.lowerPercent}% to {result.confidenceInterval.upperPercent}%
+          </p>
+        )}
       </div>
 
+      {result.warnings && result.warnings.length > 0 && (
+        <div className="mb-4 rounded-md border border-yellow-200 bg-yellow-50 p-3">
+          {result.warnings.map((warning) => (
+            <p key={warning.code} className="text-sm text-yellow-800">
+              {warning.message.charAt(0).toUpperCase() + warning.message.slice(1)}
+            </p>
+          ))}
+        </div>
+      )}
+
       <div className="mt-4 border-t pt-4">
         <p className="text-xs text-gray-500">
           <strong>Disclaimer:</strong> The information you enter is not stored and is only used to calculate your chances of success. 
@@ -1,4 +1,4 @@
-import type { CalculateRequest, CalculateResponse } from '../types/calculate'
+import type { CalculateRequest, CalculateResponse, FieldError, ValidationSchema } from '../types/calculate'
 
 const API_BASE = 'http://localhost:8080'
 
@@ -15,8 +15,23 @@ export async function calculate(
 
   if (!response.ok) {
     const error = await response.json()
-    throw new Error(error.details ? JSON.stringify(error.details) : 'Request failed')
+    if (Array.isArray(error.errors)) {
+      throw new Error(
+        error.errors
+          .map((e: FieldError) => (e.field ? `${e.field}: ${e.message}` : e.message))
+          .join('; ')
+      )
+    }
+    throw new Error(error.details ?? error.error ?? 'Request failed')
   }
 
   return response.json()
 }
+
+export async function fetchSchema(): Promise<ValidationSchema> {
+  const response = await fetch(`${API_BASE}/api/calculate/schema`)
+  if (!response.ok) {
+    throw new Error('Failed to load validation rules')
+  }
+  return response.json()
+}
@@ -17,9 +17,12 @@ export type PriorIvfCyclesOption = typeof priorIvfCyclesOptions[number]
 
 export interface CalculateRequest {
   age: number
-  weightLbs: number
-  heightFt: number
-  heightIn: number
+  weightLbs?: number
+  heightFt?: number
+  heightIn?: number
+  weightKg?: number
+  heightCm?: number
+  bmi?: number
   eggSource: EggSource
   priorIvfCycles: PriorIvfCyclesOption
   priorPregnancies: number
@@ -27,8 +30,18 @@ export interface CalculateRequest {
   reasons: CalculateReason[]
 }
 
+export interface ConfidenceInterval {
+  level: number
+  available: boolean
+  lowerPercent?: number
+  upperPercent?: number
+  reason?: string
+}
+
 export interface CalculateResponse {
   cumulativeChancePercent: number
+  confidenceInterval?: ConfidenceInterval
+  warnings?: FieldError[]
 }
 
 export interface CalculateFormData {
@@ -54,3 +67,42 @@ export interface FormErrors {
   priorBirths?: string
   reasons?: string
 }
+
+export interface FieldError {
+  field: string
+  code: string
+  message: string
+  params?: Record<string, unknown>
+}
+
+export interface ValidationErrorResponse {
+  errors: FieldError[]
+}
+
+export interface ValidationRule {
+  field: string
+  type: 'integer' | 'number' | 'string' | 'string[]'
+  required?: boolean
+  min?: number
+  max?: number
+  default?: number
+  enum?: string[]
+  minItems?: number
+  unitSystem?: string
+  labels?: Record<string, string>
+}
+
+export interface ValidationConstraint {
+  kind: 'one_unit_system' | 'required_if' | 'not_above' | 'exclusive'
+  field?: string
+  other?: string
+  values?: string[]
+  groups?: { unitSystem: string; fields: string[] }[]
+  default?: string
+}
+
+export interface ValidationSchema {
+  locale: string
+  fields: ValidationRule[]
+  constraints: ValidationConstraint[]
+}